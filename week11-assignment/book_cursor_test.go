@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newQueryContext(query url.Values) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/books?"+query.Encode(), nil)
+	return c
+}
+
+func cursorFor(sort string, values ...interface{}) string {
+	return encodeBookCursor(bookCursor{Sort: sort, Values: values})
+}
+
+func TestParseBookListQueryCursor(t *testing.T) {
+	tests := []struct {
+		name   string
+		sort   string
+		cursor string
+		ok     bool
+	}{
+		{"next page of default sort", "", cursorFor("id", 42), true},
+		{"multi-key sort", "-price,title", cursorFor("-price,title,id", 199.5, "Go", 7), true},
+		{"timestamp key", "-created_at", cursorFor("-created_at,id", "2024-05-01T10:00:00.123456Z", 3), true},
+		{"numeric key", "-rating", cursorFor("-rating,id", 4.5, 1), true},
+		{"not base64", "", "%%%", false},
+		{"not json", "", "bm90IGpzb24", false},
+		{"cursor from another sort", "title", cursorFor("id", 42), false},
+		{"missing tiebreaker value", "title", cursorFor("title,id", "Go"), false},
+		{"string for int column", "", cursorFor("id", "42"), false},
+		{"fraction for int column", "year", cursorFor("year,id", 2001.5, 1), false},
+		{"int column overflow", "", cursorFor("id", 1e12), false},
+		{"number for text column", "title", cursorFor("title,id", 12, 1), false},
+		{"string for numeric column", "price", cursorFor("price,id", "cheap", 1), false},
+		{"malformed timestamp", "created_at", cursorFor("created_at,id", "yesterday", 1), false},
+		{"null value", "", cursorFor("id", nil), false},
+		{"nested value", "", cursorFor("id", []int{1}), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := url.Values{"cursor": {tt.cursor}}
+			if tt.sort != "" {
+				query.Set("sort", tt.sort)
+			}
+			q, err := parseBookListQuery(newQueryContext(query), "id", 20)
+			if tt.ok {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if q.cursor == nil || len(q.cursor.Values) != len(q.sort) {
+					t.Fatalf("cursor not applied: %+v", q.cursor)
+				}
+				return
+			}
+			if err == nil || err.Error() != "invalid cursor" {
+				t.Fatalf("err = %v, want invalid cursor", err)
+			}
+		})
+	}
+}
+
+func TestBookCursorRoundTrip(t *testing.T) {
+	book := Book{ID: 9, Title: "Go", Price: 250}
+	q, err := parseBookListQuery(newQueryContext(url.Values{"sort": {"-price,title"}}), "id", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cursor := bookCursor{Sort: q.sortString()}
+	for _, key := range q.sort {
+		cursor.Values = append(cursor.Values, bookSortColumns[key.name].value(book))
+	}
+
+	next, err := parseBookListQuery(newQueryContext(url.Values{
+		"sort":   {"-price,title"},
+		"cursor": {encodeBookCursor(cursor)},
+	}), "id", 20)
+	if err != nil {
+		t.Fatalf("cursor built by listBooks rejected: %v", err)
+	}
+	if got := next.cursor.Values[1]; got != "Go" {
+		t.Fatalf("title value = %v, want Go", got)
+	}
+}
+
+func TestParseBookListQueryLimit(t *testing.T) {
+	for _, limit := range []string{"0", "-1", "101", "abc"} {
+		if _, err := parseBookListQuery(newQueryContext(url.Values{"limit": {limit}}), "id", 20); err == nil {
+			t.Errorf("limit=%s accepted", limit)
+		}
+	}
+	q, err := parseBookListQuery(newQueryContext(url.Values{"limit": {"100"}}), "id", 20)
+	if err != nil || q.limit != 100 {
+		t.Fatalf("limit=100: q=%+v err=%v", q, err)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+func TestCheckPatchFieldComputedPrice(t *testing.T) {
+	for _, field := range []string{"original_price", "discount", "sale_price"} {
+		err := checkPatchField(field)
+		if err == nil || !strings.Contains(err.Error(), "computed from promotions") {
+			t.Errorf("%s: err = %v, want the computed-price error", field, err)
+		}
+	}
+	if err := checkPatchField("price"); err != nil {
+		t.Errorf("price: unexpected error %v", err)
+	}
+}
+
+// loadPricedBook อ่านหนังสือด้วย bookColumns แบบเดียวกับ handler
+func loadPricedBook(t *testing.T, tx *sql.Tx, bookID int) Book {
+	t.Helper()
+	book, err := scanBook(tx.QueryRow("SELECT "+bookColumns+" FROM "+bookFrom+" WHERE books.id = $1", bookID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return book
+}
+
+func TestBookColumnsSalePrice(t *testing.T) {
+	conn := openTestDB(t)
+	withTestTx(t, conn, func(tx *sql.Tx) {
+		bookID := insertBookWithHistory(t, tx, 200, nil)
+
+		book := loadPricedBook(t, tx, bookID)
+		if book.SalePrice != 200 || book.Discount != 0 || book.OriginalPrice != nil {
+			t.Fatalf("without promotion: sale=%v discount=%v original=%v, want 200, 0 and nil",
+				book.SalePrice, book.Discount, book.OriginalPrice)
+		}
+
+		addBookPromotion(t, tx, bookID, 25)
+		book = loadPricedBook(t, tx, bookID)
+		if book.Price != 200 || book.SalePrice != 150 || book.Discount != 25 {
+			t.Fatalf("with promotion: price=%v sale=%v discount=%v, want 200, 150 and 25",
+				book.Price, book.SalePrice, book.Discount)
+		}
+		if book.OriginalPrice == nil || *book.OriginalPrice != 200 {
+			t.Fatalf("original_price = %v, want the list price 200", book.OriginalPrice)
+		}
+	})
+}
+
+func TestReturningBookUsesNewPrice(t *testing.T) {
+	conn := openTestDB(t)
+	withTestTx(t, conn, func(tx *sql.Tx) {
+		bookID := insertBookWithHistory(t, tx, 200, nil)
+		addBookPromotion(t, tx, bookID, 25)
+
+		book, err := scanBook(tx.QueryRow(returningBook("UPDATE books SET price = 400 WHERE id = $1"), bookID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if book.ID != bookID || book.Price != 400 || book.SalePrice != 300 {
+			t.Fatalf("id=%d price=%v sale=%v, want %d, 400 and 300", book.ID, book.Price, book.SalePrice, bookID)
+		}
+	})
+}
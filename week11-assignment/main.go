@@ -1,17 +1,42 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
 	"log"
+	"math"
+	"mime"
 	"net/http"
 	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 	_ "week11-assignment/docs"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	_ "github.com/swaggo/swag"
@@ -31,20 +56,45 @@ type Book struct {
 	Year   int     `json:"year"`
 	Price  float64 `json:"price"`
 
-	Category      string   `json:"category"`
+	// Rating และ ReviewsCount คำนวณจากรีวิวโดย week13-lab6 (ดู README) API นี้อ่านอย่างเดียว
+	Category     string  `json:"category"`
+	CoverImage   string  `json:"cover_image"`
+	Rating       float64 `json:"rating"`
+	ReviewsCount int     `json:"reviews_count"`
+	IsNew        bool    `json:"is_new"`
+	Pages        *int    `json:"pages,omitempty"`
+	Language     string  `json:"language"`
+	Publisher    string  `json:"publisher"`
+	Description  string  `json:"description"`
+
+	// Price คือราคาปกติ SalePrice และ Discount (เปอร์เซ็นต์) คำนวณจากโปรโมชันที่กำลังใช้งาน
+	// (ดู migrations/009) จึงเป็นค่าอ่านอย่างเดียว
+	SalePrice float64 `json:"sale_price"`
+	Discount  int     `json:"discount"`
+
+	// LowestPrice30d คือราคาขายที่ต่ำที่สุดใน 30 วันก่อนราคาขายปัจจุบันเริ่มมีผล (ดู migrations/010)
+	// ใช้เทียบกับ SalePrice เพื่อไม่แสดงส่วนลดจากราคาที่เพิ่งปรับขึ้น เป็น null ถ้าไม่มีประวัติก่อนหน้า
+	LowestPrice30d *float64 `json:"lowest_price_30d"`
+
+	// OriginalPrice เลิกใช้แล้ว คงไว้ให้ client ที่ยังอ่าน original_price จากก่อน migrations/009
+	// มีค่าเท่ากับ price เฉพาะตอนที่โปรโมชันทำให้ sale_price ต่ำกว่า price และแก้ผ่าน PUT/PATCH ไม่ได้
 	OriginalPrice *float64 `json:"original_price,omitempty"`
-	Discount      int      `json:"discount"`
-	CoverImage    string   `json:"cover_image"`
-	Rating        float64  `json:"rating"`
-	ReviewsCount  int      `json:"reviews_count"`
-	IsNew         bool     `json:"is_new"`
-	Pages         *int     `json:"pages,omitempty"`
-	Language      string   `json:"language"`
-	Publisher     string   `json:"publisher"`
-	Description   string   `json:"description"`
+
+	// Authors, PublisherID และ CategoryID ผูกจาก author, publisher และ category โดย trigger ในฐานข้อมูล
+	// (ดู migrations/007) จึงเป็นค่าอ่านอย่างเดียว
+	Authors     []AuthorRef `json:"authors,omitempty"`
+	PublisherID *int        `json:"publisher_id"`
+	CategoryID  *int        `json:"category_id"`
+
+	// StockAvailable คือจำนวนที่ยังขายได้ (on_hand - reserved ใน book_inventory) ปรับยอดผ่าน week13-lab6
+	StockAvailable int `json:"stock_available"`
 
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// Rank และ Highlights มีค่าเฉพาะผลลัพธ์จาก /books/search
+	Rank       *float64          `json:"rank,omitempty"`
+	Highlights map[string]string `json:"highlights,omitempty"`
 }
 
 func initDB() {
@@ -91,6 +141,14 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvDuration อ่าน duration จาก env เช่น "720h" ค่าที่ผิดหรือไม่เป็นบวกใช้ค่า default
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil && value > 0 {
+		return value
+	}
+	return defaultValue
+}
+
 func getHealth(c *gin.Context) {
 	err := db.Ping()
 	if err != nil {
@@ -100,6 +158,661 @@ func getHealth(c *gin.Context) {
 	c.JSON(200, gin.H{"message": "healthy"})
 }
 
+// bookColumns คือคอลัมน์ที่ SELECT ทุก query ของ books ต้องเรียงตรงกับ scanBook
+const bookColumns = `id, title, author, isbn, year, price,
+               category, ` + bookSalePriceExpr + `, ` + bookDiscountExpr + `, ` + bookLowestPriceExpr + `,
+               CASE WHEN ` + bookSalePriceExpr + ` < books.price THEN books.price END, cover_image,
+               rating, reviews_count, is_new, pages,
+               language, publisher, description,
+               publisher_id, category_id,
+               COALESCE((SELECT i.on_hand - i.reserved FROM book_inventory i WHERE i.book_id = books.id), 0),
+               created_at, updated_at`
+
+// bookFrom คือ FROM ของทุก query ที่เลือก bookColumns
+// ราคาขายคำนวณครั้งเดียวต่อแถวใน pricing (ฟังก์ชันใน FROM ไม่ถูกแทนค่าซ้ำทุกที่ที่อ้างถึงเหมือนใน select list)
+// แล้ว bookSalePriceExpr bookDiscountExpr bookLowestPriceExpr และ sort ใช้ค่านั้นร่วมกัน
+const bookFrom = "books CROSS JOIN LATERAL book_sale_price(books.id, books.category_id, books.price) AS pricing(sale_price)"
+
+const (
+	bookSalePriceExpr   = "pricing.sale_price"
+	bookDiscountExpr    = "CASE WHEN books.price > 0 THEN ROUND((books.price - pricing.sale_price) * 100 / books.price)::INTEGER ELSE 0 END"
+	bookLowestPriceExpr = "book_lowest_price_30d(books.id, pricing.sale_price)"
+)
+
+// returningBook ห่อ INSERT/UPDATE ของ books ด้วย CTE ชื่อ books แล้วเลือก bookColumns จาก bookFrom
+// ใช้แทน RETURNING bookColumns เพราะ RETURNING อ้าง pricing ไม่ได้ ราคาจึงคำนวณจากค่าใหม่ของแถวทันที
+func returningBook(write string) string {
+	return "WITH books AS (" + write + " RETURNING *) SELECT " + bookColumns + " FROM " + bookFrom
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// bookScanDest คืน pointer ของ field ตามลำดับ bookColumns
+func bookScanDest(book *Book) []interface{} {
+	return []interface{}{
+		&book.ID, &book.Title, &book.Author, &book.ISBN, &book.Year, &book.Price,
+		&book.Category, &book.SalePrice, &book.Discount, &book.LowestPrice30d,
+		&book.OriginalPrice, &book.CoverImage,
+		&book.Rating, &book.ReviewsCount, &book.IsNew, &book.Pages,
+		&book.Language, &book.Publisher, &book.Description,
+		&book.PublisherID, &book.CategoryID,
+		&book.StockAvailable,
+		&book.CreatedAt, &book.UpdatedAt,
+	}
+}
+
+func scanBook(row rowScanner) (Book, error) {
+	var book Book
+	err := row.Scan(bookScanDest(&book)...)
+	return book, err
+}
+
+// queryer คือส่วนที่ *sql.DB และ *sql.Tx มีร่วมกัน
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// attachBookAuthors เติม Authors ของทุกเล่มใน books ด้วย query เดียว
+// ใช้ tx ที่เพิ่งเขียน books เพื่อให้เห็น book_authors ที่ trigger เพิ่งสร้าง
+func attachBookAuthors(q queryer, books []Book) error {
+	if len(books) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(books))
+	index := make(map[int]int, len(books))
+	for i, book := range books {
+		ids[i] = int64(book.ID)
+		index[book.ID] = i
+	}
+
+	rows, err := q.Query(`
+        SELECT ba.book_id, a.id, a.name
+        FROM book_authors ba
+        JOIN authors a ON a.id = ba.author_id
+        WHERE ba.book_id = ANY($1)
+        ORDER BY ba.book_id, ba.position`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bookID int
+		var author AuthorRef
+		if err := rows.Scan(&bookID, &author.ID, &author.Name); err != nil {
+			return err
+		}
+		i := index[bookID]
+		books[i].Authors = append(books[i].Authors, author)
+	}
+	return rows.Err()
+}
+
+// attachAuthors คือ attachBookAuthors สำหรับหนังสือเล่มเดียว
+func attachAuthors(q queryer, book *Book) error {
+	books := []Book{*book}
+	if err := attachBookAuthors(q, books); err != nil {
+		return err
+	}
+	*book = books[0]
+	return nil
+}
+
+// BookListResponse คือรูปแบบ response ของ endpoint ที่คืนรายการหนังสือแบบแบ่งหน้า
+type BookListResponse struct {
+	Data       interface{} `json:"data"`
+	NextCursor *string     `json:"next_cursor"`
+	Total      int         `json:"total"`
+	Limit      int         `json:"limit"`
+}
+
+const maxBookPageSize = 100
+
+// bookSortColumn อธิบายคอลัมน์ที่อนุญาตให้ sort ได้
+// expr ใช้ทั้งใน ORDER BY และในเงื่อนไข cursor จึงต้อง COALESCE คอลัมน์ที่เป็น NULL ได้
+// relevance ไม่มี expr ตายตัวเพราะขึ้นกับคำค้น ดู bookListQuery.sortExpr
+type bookSortColumn struct {
+	expr    string
+	sqlType string
+	value   func(Book) interface{}
+}
+
+var bookSortColumns = map[string]bookSortColumn{
+	"id":            {"id", "int", func(b Book) interface{} { return b.ID }},
+	"title":         {"title", "text", func(b Book) interface{} { return b.Title }},
+	"author":        {"COALESCE(author, '')", "text", func(b Book) interface{} { return b.Author }},
+	"year":          {"COALESCE(year, 0)", "int", func(b Book) interface{} { return b.Year }},
+	"price":         {"COALESCE(price, 0)", "numeric", func(b Book) interface{} { return b.Price }},
+	"sale_price":    {"COALESCE(" + bookSalePriceExpr + ", 0)", "numeric", func(b Book) interface{} { return b.SalePrice }},
+	"discount":      {"COALESCE(" + bookDiscountExpr + ", 0)", "int", func(b Book) interface{} { return b.Discount }},
+	"rating":        {"COALESCE(rating, 0)", "numeric", func(b Book) interface{} { return b.Rating }},
+	"reviews_count": {"COALESCE(reviews_count, 0)", "int", func(b Book) interface{} { return b.ReviewsCount }},
+	"pages":         {"COALESCE(pages, 0)", "int", func(b Book) interface{} { return derefInt(b.Pages) }},
+	"created_at":    {"created_at", "timestamptz", func(b Book) interface{} { return b.CreatedAt.Format(time.RFC3339Nano) }},
+	"updated_at":    {"updated_at", "timestamptz", func(b Book) interface{} { return b.UpdatedAt.Format(time.RFC3339Nano) }},
+	"relevance":     {"", "real", func(b Book) interface{} { return derefFloat(b.Rank) }},
+}
+
+// isUniqueViolation ตรวจว่า error มาจาก unique constraint (เช่น ISBN ซ้ำ)
+func isUniqueViolation(err error) bool {
+	if pqErr, ok := err.(*pq.Error); ok {
+		return pqErr.Code == "23505"
+	}
+	return false
+}
+
+func derefInt(p *int) int {
+	if p == nil {
+		return 0
+	}
+	return *p
+}
+
+func derefFloat(p *float64) float64 {
+	if p == nil {
+		return 0
+	}
+	return *p
+}
+
+// bookFields คือชื่อ field (ตาม json tag ของ Book) ที่เลือกได้ผ่าน fields=
+var bookFields = map[string]bool{
+	"id": true, "title": true, "author": true, "isbn": true, "year": true, "price": true,
+	"category": true, "sale_price": true, "discount": true, "lowest_price_30d": true,
+	"original_price": true, "cover_image": true, "rating": true, "reviews_count": true, "is_new": true, "pages": true,
+	"language": true, "publisher": true, "description": true,
+	"authors": true, "publisher_id": true, "category_id": true, "stock_available": true,
+	"created_at": true, "updated_at": true,
+	"rank": true, "highlights": true,
+}
+
+type bookSortKey struct {
+	name string
+	desc bool
+}
+
+// bookCursor ถูก encode เป็น base64 ส่งให้ client แบบ opaque
+// เก็บ sort ไว้ด้วยเพื่อปฏิเสธ cursor ที่ใช้คู่กับ sort คนละชุด
+type bookCursor struct {
+	Sort   string        `json:"s"`
+	Values []interface{} `json:"v"`
+}
+
+type bookListQuery struct {
+	conditions []string
+	args       []interface{}
+	sort       []bookSortKey
+	limit      int
+	cursor     *bookCursor
+	fields     []string
+
+	// facets[i] คือชื่อ facet ที่ conditions[i] กรอง (ว่างถ้าไม่ใช่ filter ของ facet ใด)
+	// /books/facets ใช้ตัดเงื่อนไขของ facet นั้นออกตอนนับค่าของมันเอง
+	facets []string
+
+	// tsquery เป็น SQL expression ของคำค้น ตั้งโดย searchBooks เท่านั้น
+	tsquery string
+}
+
+// arg เพิ่ม value เข้า args แล้วคืน placeholder สำหรับอ้างอิงซ้ำได้หลายที่
+func (q *bookListQuery) arg(value interface{}) string {
+	q.args = append(q.args, value)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+func (q *bookListQuery) sortExpr(name string) string {
+	if name == "relevance" {
+		return "ts_rank(search_vector, " + q.tsquery + ")"
+	}
+	return bookSortColumns[name].expr
+}
+
+// where เพิ่มเงื่อนไขโดยแทน ? แต่ละตัวด้วย placeholder ของ args ตามลำดับ
+func (q *bookListQuery) where(cond string, args ...interface{}) {
+	for _, arg := range args {
+		q.args = append(q.args, arg)
+		cond = strings.Replace(cond, "?", fmt.Sprintf("$%d", len(q.args)), 1)
+	}
+	q.conditions = append(q.conditions, cond)
+	q.facets = append(q.facets, "")
+}
+
+// whereFacet เหมือน where แต่ระบุว่าเงื่อนไขนี้เป็น filter ของ facet ใด
+func (q *bookListQuery) whereFacet(facet, cond string, args ...interface{}) {
+	q.where(cond, args...)
+	q.facets[len(q.facets)-1] = facet
+}
+
+func (q *bookListQuery) sortString() string {
+	keys := make([]string, len(q.sort))
+	for i, key := range q.sort {
+		if key.desc {
+			keys[i] = "-" + key.name
+		} else {
+			keys[i] = key.name
+		}
+	}
+	return strings.Join(keys, ",")
+}
+
+func parseBookSort(param string) ([]bookSortKey, error) {
+	var keys []bookSortKey
+	seen := map[string]bool{}
+	for _, part := range strings.Split(param, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key := bookSortKey{name: strings.TrimPrefix(part, "-"), desc: strings.HasPrefix(part, "-")}
+		if _, ok := bookSortColumns[key.name]; !ok {
+			return nil, fmt.Errorf("cannot sort by %q", key.name)
+		}
+		if seen[key.name] {
+			return nil, fmt.Errorf("duplicate sort key %q", key.name)
+		}
+		seen[key.name] = true
+		keys = append(keys, key)
+	}
+
+	// ใส่ id เป็นตัวตัดสินสุดท้ายเสมอ เพื่อให้ลำดับคงที่และ cursor ไม่ข้ามหรือซ้ำแถว
+	if !seen["id"] {
+		keys = append(keys, bookSortKey{name: "id"})
+	}
+	return keys, nil
+}
+
+func encodeBookCursor(cursor bookCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeBookCursor(s string) (*bookCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var cursor bookCursor
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&cursor); err != nil {
+		return nil, err
+	}
+	for _, v := range cursor.Values {
+		switch v.(type) {
+		case json.Number, string:
+		default:
+			return nil, errors.New("unexpected cursor value")
+		}
+	}
+	return &cursor, nil
+}
+
+// validCursorValue ตรวจว่าค่าใน cursor แปลงเป็นชนิดของคอลัมน์ sort ได้
+// cursor มาจาก client จึงต้องตรวจก่อน ไม่อย่างนั้น cast ใน SQL จะล้มเป็น 500
+func validCursorValue(sqlType string, v interface{}) bool {
+	switch sqlType {
+	case "int":
+		n, ok := v.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := strconv.ParseInt(n.String(), 10, 32)
+		return err == nil
+	case "numeric", "real":
+		n, ok := v.(json.Number)
+		if !ok {
+			return false
+		}
+		f, err := n.Float64()
+		return err == nil && !math.IsInf(f, 0) && !math.IsNaN(f)
+	case "text":
+		text, ok := v.(string)
+		return ok && utf8.ValidString(text) && !strings.ContainsRune(text, 0)
+	case "timestamptz":
+		text, ok := v.(string)
+		if !ok {
+			return false
+		}
+		_, err := time.Parse(time.RFC3339Nano, text)
+		return err == nil
+	}
+	return false
+}
+
+// parseBookListQuery อ่าน query parameter ที่ใช้ร่วมกันของทุก endpoint ที่คืนรายการหนังสือ
+// error ที่คืนกลับเป็นความผิดพลาดของ client ทั้งหมด (400)
+func parseBookListQuery(c *gin.Context, defaultSort string, defaultLimit int) (*bookListQuery, error) {
+	q := &bookListQuery{limit: defaultLimit}
+
+	if s := c.Query("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxBookPageSize {
+			return nil, fmt.Errorf("limit must be between 1 and %d", maxBookPageSize)
+		}
+		q.limit = limit
+	}
+
+	keys, err := parseBookSort(c.DefaultQuery("sort", defaultSort))
+	if err != nil {
+		return nil, err
+	}
+	q.sort = keys
+
+	if s := c.Query("cursor"); s != "" {
+		cursor, err := decodeBookCursor(s)
+		if err != nil || cursor.Sort != q.sortString() || len(cursor.Values) != len(q.sort) {
+			return nil, errors.New("invalid cursor")
+		}
+		for i, key := range q.sort {
+			if !validCursorValue(bookSortColumns[key.name].sqlType, cursor.Values[i]) {
+				return nil, errors.New("invalid cursor")
+			}
+		}
+		q.cursor = cursor
+	}
+
+	if s := c.Query("fields"); s != "" {
+		for _, field := range strings.Split(s, ",") {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				continue
+			}
+			if !bookFields[field] {
+				return nil, fmt.Errorf("unknown field %q", field)
+			}
+			q.fields = append(q.fields, field)
+		}
+	}
+
+	if err := parseBookFilters(c, q); err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// parseBookFilters เพิ่มเงื่อนไข filter จาก query parameter ลงใน q
+// ใช้ร่วมกันระหว่าง endpoint รายการหนังสือและ /books/facets
+func parseBookFilters(c *gin.Context, q *bookListQuery) error {
+	// หนังสือที่อยู่ในถังขยะ (soft delete) ไม่แสดงในทุก listing
+	q.where("deleted_at IS NULL")
+
+	if s := c.Query("year"); s != "" {
+		year, err := strconv.Atoi(s)
+		if err != nil {
+			return errors.New("year must be an integer")
+		}
+		q.whereFacet("year", "year = ?", year)
+	}
+	if s := c.Query("category"); s != "" {
+		q.whereFacet("category", "category = ?", s)
+	}
+	if s := c.Query("language"); s != "" {
+		q.whereFacet("language", "language = ?", s)
+	}
+	if s := c.Query("publisher"); s != "" {
+		q.whereFacet("publisher", "publisher = ?", s)
+	}
+
+	if s := c.Query("in_stock"); s != "" {
+		inStock, err := strconv.ParseBool(s)
+		if err != nil {
+			return errors.New("in_stock must be true or false")
+		}
+		cond := "EXISTS (SELECT 1 FROM book_inventory i WHERE i.book_id = books.id AND i.on_hand > i.reserved)"
+		if !inStock {
+			cond = "NOT " + cond
+		}
+		q.where(cond)
+	}
+
+	ids := []struct {
+		param string
+		facet string
+		cond  string
+	}{
+		{"author_id", "", "id IN (SELECT book_id FROM book_authors WHERE author_id = ?)"},
+		{"publisher_id", "publisher", "publisher_id = ?"},
+		{"category_id", "category", "category_id = ?"},
+	}
+	for _, f := range ids {
+		s := c.Query(f.param)
+		if s == "" {
+			continue
+		}
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("%s must be an integer", f.param)
+		}
+		q.whereFacet(f.facet, f.cond, id)
+	}
+
+	// ช่วงราคาเทียบกับราคาขาย (หลังโปรโมชัน) ตามที่หน้าร้านแสดง
+	ranges := []struct {
+		param string
+		facet string
+		cond  string
+	}{
+		{"min_price", "price", bookSalePriceExpr + " >= ?"},
+		{"max_price", "price", bookSalePriceExpr + " <= ?"},
+		{"min_year", "year", "year >= ?"},
+		{"max_year", "year", "year <= ?"},
+	}
+	for _, r := range ranges {
+		s := c.Query(r.param)
+		if s == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("%s must be a number", r.param)
+		}
+		q.whereFacet(r.facet, r.cond, v)
+	}
+
+	return nil
+}
+
+// selectBookFields คืนเฉพาะ field ที่ขอผ่าน fields= โดยอ้างอิงชื่อจาก json tag ของ Book
+func selectBookFields(books []Book, fields []string) ([]map[string]json.RawMessage, error) {
+	result := make([]map[string]json.RawMessage, 0, len(books))
+	for _, book := range books {
+		data, err := json.Marshal(book)
+		if err != nil {
+			return nil, err
+		}
+		var all map[string]json.RawMessage
+		if err := json.Unmarshal(data, &all); err != nil {
+			return nil, err
+		}
+		item := make(map[string]json.RawMessage, len(fields))
+		for _, field := range fields {
+			if v, ok := all[field]; ok {
+				item[field] = v
+			}
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+// listBooks รัน query ตาม bookListQuery แล้วตอบกลับเป็น BookListResponse
+// total นับจากเงื่อนไข filter อย่างเดียว ไม่รวมตำแหน่ง cursor
+func listBooks(c *gin.Context, q *bookListQuery) {
+	where := ""
+	if len(q.conditions) > 0 {
+		where = " WHERE " + strings.Join(q.conditions, " AND ")
+	}
+
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) FROM "+bookFrom+where, q.args...).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	conditions := append([]string{}, q.conditions...)
+	args := append([]interface{}{}, q.args...)
+	orderBy := make([]string, len(q.sort))
+	for i, key := range q.sort {
+		if key.name == "relevance" && q.tsquery == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "sort by relevance requires a search query"})
+			return
+		}
+		orderBy[i] = q.sortExpr(key.name)
+		if key.desc {
+			orderBy[i] += " DESC"
+		}
+	}
+
+	// keyset: (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ... โดยกลับเครื่องหมายสำหรับคีย์ที่เรียง DESC
+	if q.cursor != nil {
+		placeholders := make([]string, len(q.sort))
+		for i, key := range q.sort {
+			args = append(args, q.cursor.Values[i])
+			placeholders[i] = fmt.Sprintf("$%d::%s", len(args), bookSortColumns[key.name].sqlType)
+		}
+		var ors []string
+		for i, key := range q.sort {
+			var ands []string
+			for j := 0; j < i; j++ {
+				ands = append(ands, q.sortExpr(q.sort[j].name)+" = "+placeholders[j])
+			}
+			op := " > "
+			if key.desc {
+				op = " < "
+			}
+			ands = append(ands, q.sortExpr(key.name)+op+placeholders[i])
+			ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+		}
+		conditions = append(conditions, "("+strings.Join(ors, " OR ")+")")
+	}
+
+	query := "SELECT " + bookColumns
+	if q.tsquery != "" {
+		query += ", " + q.sortExpr("relevance") +
+			", ts_headline(books_search_config(), title, " + q.tsquery + ", '" + headlineOptions + ", HighlightAll=true')" +
+			", ts_headline(books_search_config(), COALESCE(description, ''), " + q.tsquery + ", '" + headlineOptions + ", MaxWords=35, MinWords=15')"
+	}
+	query += " FROM " + bookFrom
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	// ดึงเกินมาหนึ่งแถวเพื่อรู้ว่ายังมีหน้าถัดไปหรือไม่
+	query += fmt.Sprintf(" ORDER BY %s LIMIT %d", strings.Join(orderBy, ", "), q.limit+1)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	books := []Book{}
+	for rows.Next() {
+		var book Book
+		dest := bookScanDest(&book)
+		var rank float64
+		var titleHeadline, descriptionHeadline string
+		if q.tsquery != "" {
+			dest = append(dest, &rank, &titleHeadline, &descriptionHeadline)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if q.tsquery != "" {
+			book.Rank = &rank
+			book.Highlights = map[string]string{"title": titleHeadline, "description": descriptionHeadline}
+		}
+		books = append(books, book)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := BookListResponse{Total: total, Limit: q.limit}
+	if len(books) > q.limit {
+		books = books[:q.limit]
+		last := books[len(books)-1]
+		cursor := bookCursor{Sort: q.sortString()}
+		for _, key := range q.sort {
+			cursor.Values = append(cursor.Values, bookSortColumns[key.name].value(last))
+		}
+		next := encodeBookCursor(cursor)
+		resp.NextCursor = &next
+	}
+
+	if err := attachBookAuthors(db, books); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(q.fields) > 0 {
+		items, err := selectBookFields(books, q.fields)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		resp.Data = items
+	} else {
+		resp.Data = books
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// requireIfMatch บังคับให้ PUT/PATCH/DELETE ส่ง If-Match มาด้วย (ตอบ 428 ถ้าไม่ส่ง)
+var requireIfMatch = getEnv("REQUIRE_IF_MATCH", "false") == "true"
+
+// bookETag สร้าง strong ETag จาก id และ updated_at ซึ่ง trigger update_modified_column อัปเดตทุกครั้งที่แก้ไข
+func bookETag(book Book) string {
+	return fmt.Sprintf(`"%d-%d"`, book.ID, book.UpdatedAt.UnixMicro())
+}
+
+// etagMatches เทียบ ETag กับค่าใน If-Match / If-None-Match ซึ่งอาจมีหลายค่าคั่นด้วย comma
+// ใช้ strong comparison: weak ETag (W/"...") ไม่ถือว่าตรง
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatchPresent ตอบ 428 ถ้าตั้ง REQUIRE_IF_MATCH ไว้แต่ request ไม่มี If-Match
+func checkIfMatchPresent(c *gin.Context) bool {
+	if requireIfMatch && c.GetHeader("If-Match") == "" {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header is required"})
+		return false
+	}
+	return true
+}
+
+// checkIfMatch ตอบ 412 ถ้า If-Match ไม่ตรงกับ version ปัจจุบันของ book
+func checkIfMatch(c *gin.Context, current Book) bool {
+	header := c.GetHeader("If-Match")
+	if header != "" && !etagMatches(header, bookETag(current)) {
+		c.Header("ETag", bookETag(current))
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "book has been modified"})
+		return false
+	}
+	return true
+}
+
+// lockBook อ่าน book พร้อมล็อกแถวไว้จนจบ transaction
+// เพื่อให้การตรวจ If-Match และการเขียนเกิดขึ้นกับ version เดียวกัน
+func lockBook(tx *sql.Tx, id string) (Book, error) {
+	return scanBook(tx.QueryRow(`
+        SELECT `+bookColumns+`
+        FROM `+bookFrom+` WHERE id = $1 AND deleted_at IS NULL
+        FOR UPDATE OF books`, id))
+}
+
 // @Summary     Get book by ID
 // @Description Get details of specific book
 // @Tags        Books
@@ -116,28 +829,19 @@ func getHealth(c *gin.Context) {
 // @Accept      json
 // @Produce     json
 // @Param       id   path      int  true  "Book ID"
+// @Param       If-None-Match  header  string  false  "ETag from a previous response"
 // @Success     200  {object}  Book
+// @Header      200  {string}  ETag  "Current version of the book"
+// @Success     304  {string}  string  "Not modified"
 // @Failure     404  {object}  ErrorResponse
 // @Failure     500  {object}  ErrorResponse
 // @Router      /books/{id} [get]
 func getBook(c *gin.Context) {
 	id := c.Param("id")
-	var book Book
 
-	err := db.QueryRow(`
-        SELECT id, title, author, isbn, year, price,
-               category, original_price, discount, cover_image,
-               rating, reviews_count, is_new, pages,
-               language, publisher, description,
-               created_at, updated_at
-        FROM books WHERE id = $1`, id).
-		Scan(
-			&book.ID, &book.Title, &book.Author, &book.ISBN, &book.Year, &book.Price,
-			&book.Category, &book.OriginalPrice, &book.Discount, &book.CoverImage,
-			&book.Rating, &book.ReviewsCount, &book.IsNew, &book.Pages,
-			&book.Language, &book.Publisher, &book.Description,
-			&book.CreatedAt, &book.UpdatedAt,
-		)
+	book, err := scanBook(db.QueryRow(`
+        SELECT `+bookColumns+`
+        FROM `+bookFrom+` WHERE id = $1 AND deleted_at IS NULL`, id))
 
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "book not found"})
@@ -147,6 +851,18 @@ func getBook(c *gin.Context) {
 		return
 	}
 
+	etag := bookETag(book)
+	c.Header("ETag", etag)
+	if header := c.GetHeader("If-None-Match"); header != "" && etagMatches(header, etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	if err := attachAuthors(db, &book); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusOK, book)
 }
 
@@ -161,13 +877,9 @@ func getBook(c *gin.Context) {
 // @Router      /books/new [get]
 func getNewBooks(c *gin.Context) {
 	rows, err := db.Query(`
-        SELECT id, title, author, isbn, year, price,
-               category, original_price, discount, cover_image,
-               rating, reviews_count, is_new, pages,
-               language, publisher, description,
-               created_at, updated_at
-        FROM books 
-        WHERE is_new = true
+        SELECT ` + bookColumns + `
+        FROM ` + bookFrom + `
+        WHERE is_new = true AND deleted_at IS NULL
         ORDER BY created_at DESC 
         LIMIT 5
     `)
@@ -180,14 +892,7 @@ func getNewBooks(c *gin.Context) {
 
 	var books []Book
 	for rows.Next() {
-		var book Book
-		err := rows.Scan(
-			&book.ID, &book.Title, &book.Author, &book.ISBN, &book.Year, &book.Price,
-			&book.Category, &book.OriginalPrice, &book.Discount, &book.CoverImage,
-			&book.Rating, &book.ReviewsCount, &book.IsNew, &book.Pages,
-			&book.Language, &book.Publisher, &book.Description,
-			&book.CreatedAt, &book.UpdatedAt,
-		)
+		book, err := scanBook(rows)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -198,73 +903,47 @@ func getNewBooks(c *gin.Context) {
 	if books == nil {
 		books = []Book{}
 	}
+	if err := attachBookAuthors(db, books); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	c.JSON(http.StatusOK, books)
 }
 
 // @Summary     Get all books
-// @Description Get all books or filter by year/category
+// @Description Get books page by page with filters, multi-key sorting and sparse fieldsets
 // @Tags        Books
 // @Accept      json
 // @Produce     json
-// @Param       year      query  int     false  "Filter by year"
-// @Param       category  query  string  false  "Filter by category"
-// @Success     200  {array}   Book
+// @Param       limit      query  int     false  "Page size (default 20, max 100)"
+// @Param       cursor     query  string  false  "Opaque cursor from next_cursor of the previous page"
+// @Param       sort       query  string  false  "Comma separated sort keys, prefix with - for descending (e.g. -rating,title)"
+// @Param       fields     query  string  false  "Comma separated fields to return (e.g. id,title,price)"
+// @Param       year       query  int     false  "Filter by year"
+// @Param       category   query  string  false  "Filter by category"
+// @Param       language   query  string  false  "Filter by language"
+// @Param       publisher  query  string  false  "Filter by publisher"
+// @Param       author_id  query  int     false  "Filter by author ID"
+// @Param       publisher_id  query  int  false  "Filter by publisher ID"
+// @Param       category_id   query  int  false  "Filter by category ID"
+// @Param       in_stock      query  bool false  "Only books that are (true) or are not (false) available"
+// @Param       min_price  query  number  false  "Minimum sale price"
+// @Param       max_price  query  number  false  "Maximum sale price"
+// @Param       min_year   query  int     false  "Minimum year"
+// @Param       max_year   query  int     false  "Maximum year"
+// @Success     200  {object}  BookListResponse
+// @Failure     400  {object}  ErrorResponse
 // @Failure     500  {object}  ErrorResponse
 // @Router      /books [get]
 func getAllBooks(c *gin.Context) {
-	var rows *sql.Rows
-	var err error
-
-	yearQ := c.Query("year")
-	categoryQ := c.Query("category")
-
-	baseQuery := `
-        SELECT id, title, author, isbn, year, price,
-               category, original_price, discount, cover_image,
-               rating, reviews_count, is_new, pages,
-               language, publisher, description,
-               created_at, updated_at
-        FROM books
-    `
-
-	if yearQ != "" && categoryQ != "" {
-		rows, err = db.Query(baseQuery+" WHERE year = $1 AND category = $2", yearQ, categoryQ)
-	} else if yearQ != "" {
-		rows, err = db.Query(baseQuery+" WHERE year = $1", yearQ)
-	} else if categoryQ != "" {
-		rows, err = db.Query(baseQuery+" WHERE category = $1", categoryQ)
-	} else {
-		rows, err = db.Query(baseQuery)
-	}
-
+	q, err := parseBookListQuery(c, "id", 20)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	defer rows.Close()
 
-	var books []Book
-	for rows.Next() {
-		var book Book
-		err := rows.Scan(
-			&book.ID, &book.Title, &book.Author, &book.ISBN, &book.Year, &book.Price,
-			&book.Category, &book.OriginalPrice, &book.Discount, &book.CoverImage,
-			&book.Rating, &book.ReviewsCount, &book.IsNew, &book.Pages,
-			&book.Language, &book.Publisher, &book.Description,
-			&book.CreatedAt, &book.UpdatedAt,
-		)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		books = append(books, book)
-	}
-
-	if books == nil {
-		books = []Book{}
-	}
-	c.JSON(http.StatusOK, books)
+	listBooks(c, q)
 }
 
 // @Summary Create a new book
@@ -285,34 +964,35 @@ func createBook(c *gin.Context) {
 		return
 	}
 
-	var id int
-	var createdAt, updatedAt time.Time
-
-	err := db.QueryRow(`
+	// rating และ reviews_count คำนวณจากรีวิวของลูกค้า sale_price และ discount คำนวณจากโปรโมชัน
+	// จึงไม่รับค่าจาก request
+	created, err := scanBook(db.QueryRow(returningBook(`
         INSERT INTO books (
             title, author, isbn, year, price,
-            category, original_price, discount, cover_image,
-            rating, reviews_count, is_new, pages,
+            category, cover_image,
+            is_new, pages,
             language, publisher, description
         )
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
-        RETURNING id, created_at, updated_at`,
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`),
 		newBook.Title, newBook.Author, newBook.ISBN, newBook.Year, newBook.Price,
-		newBook.Category, newBook.OriginalPrice, newBook.Discount, newBook.CoverImage,
-		newBook.Rating, newBook.ReviewsCount, newBook.IsNew, newBook.Pages,
+		newBook.Category, newBook.CoverImage,
+		newBook.IsNew, newBook.Pages,
 		newBook.Language, newBook.Publisher, newBook.Description,
-	).Scan(&id, &createdAt, &updatedAt)
+	))
 
-	if err != nil {
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "a book with this isbn already exists"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := attachAuthors(db, &created); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	newBook.ID = id
-	newBook.CreatedAt = createdAt
-	newBook.UpdatedAt = updatedAt
-
-	c.JSON(http.StatusCreated, newBook)
+	c.JSON(http.StatusCreated, created)
 }
 
 // @Summary     Update a book
@@ -320,11 +1000,14 @@ func createBook(c *gin.Context) {
 // @Tags        Books
 // @Accept      json
 // @Produce     json
-// @Param       id    path      int   true  "Book ID"
-// @Param       book  body      Book  true  "Book object"
+// @Param       id        path      int     true   "Book ID"
+// @Param       If-Match  header    string  false  "ETag of the version being replaced"
+// @Param       book      body      Book    true   "Book object"
 // @Success     200  {object}   Book
 // @Failure     400  {object}   ErrorResponse
 // @Failure     404  {object}   ErrorResponse
+// @Failure     412  {object}   ErrorResponse
+// @Failure     428  {object}   ErrorResponse
 // @Failure     500  {object}   ErrorResponse
 // @Router      /books/{id} [put]
 func updateBook(c *gin.Context) {
@@ -335,279 +1018,2582 @@ func updateBook(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !checkIfMatchPresent(c) {
+		return
+	}
 
-	var updatedAt time.Time
-	err := db.QueryRow(`
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	current, err := lockBook(tx, id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "book not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkIfMatch(c, current) {
+		return
+	}
+
+	updated, err := scanBook(tx.QueryRow(returningBook(`
         UPDATE books
         SET title = $1, author = $2, isbn = $3, year = $4, price = $5,
-            category = $6, original_price = $7, discount = $8, cover_image = $9,
-            rating = $10, reviews_count = $11, is_new = $12, pages = $13,
-            language = $14, publisher = $15, description = $16
-        WHERE id = $17
-        RETURNING updated_at`,
+            category = $6, cover_image = $7,
+            is_new = $8, pages = $9,
+            language = $10, publisher = $11, description = $12
+        WHERE id = $13`),
 		updateBook.Title, updateBook.Author, updateBook.ISBN, updateBook.Year, updateBook.Price,
-		updateBook.Category, updateBook.OriginalPrice, updateBook.Discount, updateBook.CoverImage,
-		updateBook.Rating, updateBook.ReviewsCount, updateBook.IsNew, updateBook.Pages,
+		updateBook.Category, updateBook.CoverImage,
+		updateBook.IsNew, updateBook.Pages,
 		updateBook.Language, updateBook.Publisher, updateBook.Description,
 		id,
-	).Scan(&updatedAt)
-
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "book not found"})
+	))
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "a book with this isbn already exists"})
 		return
 	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if err := attachAuthors(tx, &updated); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
-	updateBook.UpdatedAt = updatedAt
-	c.JSON(http.StatusOK, updateBook)
+	c.Header("ETag", bookETag(updated))
+	c.JSON(http.StatusOK, updated)
 }
 
-// @Summary     Delete a book
-// @Description Delete book by ID
-// @Tags        Books
-// @Accept      json
-// @Produce     json
-// @Param       id   path      int     true  "Book ID"
-// @Success     200  {object}  map[string]interface{}
-// @Failure     404  {object}  ErrorResponse
-// @Failure     500  {object}  ErrorResponse
-// @Router      /books/{id} [delete]
-func deleteBook(c *gin.Context) {
-	id := c.Param("id")
+// bookPatchColumn อธิบาย field ที่แก้ไขผ่าน PATCH ได้ ชื่อ field ตรงกับชื่อคอลัมน์
+// id, created_at, updated_at, rating, reviews_count (คำนวณจากรีวิว), sale_price, discount (คำนวณจากโปรโมชัน)
+// และ field ของผลค้นหาแก้ไขไม่ได้
+type bookPatchColumn struct {
+	nullable bool
+	value    func(b *Book) interface{}
+}
 
-	result, err := db.Exec("DELETE FROM books WHERE id = $1", id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error message": err.Error()})
-		return
+var bookPatchColumns = map[string]bookPatchColumn{
+	"title":       {false, func(b *Book) interface{} { return b.Title }},
+	"author":      {false, func(b *Book) interface{} { return b.Author }},
+	"isbn":        {false, func(b *Book) interface{} { return b.ISBN }},
+	"year":        {false, func(b *Book) interface{} { return b.Year }},
+	"price":       {false, func(b *Book) interface{} { return b.Price }},
+	"category":    {false, func(b *Book) interface{} { return b.Category }},
+	"cover_image": {false, func(b *Book) interface{} { return b.CoverImage }},
+	"is_new":      {false, func(b *Book) interface{} { return b.IsNew }},
+	"pages":       {true, func(b *Book) interface{} { return b.Pages }},
+	"language":    {false, func(b *Book) interface{} { return b.Language }},
+	"publisher":   {false, func(b *Book) interface{} { return b.Publisher }},
+	"description": {false, func(b *Book) interface{} { return b.Description }},
+}
+
+// errPatchTestFailed คือ op "test" ของ JSON Patch ที่ค่าไม่ตรง (ตอบ 409)
+var errPatchTestFailed = errors.New("test operation failed")
+
+// checkPatchField ตรวจว่า field แก้ผ่าน PATCH ได้
+// original_price และ discount เคยแก้ได้ก่อน migrations/009 จึงบอกทางใหม่แทนข้อความทั่วไป
+func checkPatchField(field string) error {
+	if _, ok := bookPatchColumns[field]; ok {
+		return nil
+	}
+	switch field {
+	case "original_price", "discount", "sale_price":
+		return fmt.Errorf("field %q is computed from promotions; set the list price in price and create a promotion in week13-lab6", field)
 	}
+	return fmt.Errorf("field %q cannot be patched", field)
+}
+
+// jsonPatchOp คือ operation หนึ่งตัวของ RFC 6902
+type jsonPatchOp struct {
+	Op    string           `json:"op"`
+	Path  string           `json:"path"`
+	From  string           `json:"from"`
+	Value *json.RawMessage `json:"value"`
+}
 
-	rowsAffected, err := result.RowsAffected()
+// bookDocument แปลง book เป็น JSON object ที่มีทุก field ที่แก้ไขได้ (nil แทน NULL)
+func bookDocument(book Book) (map[string]interface{}, error) {
+	data, err := json.Marshal(book)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error message": err.Error()})
-		return
+		return nil, err
+	}
+	doc := map[string]interface{}{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	for field := range bookPatchColumns {
+		if _, ok := doc[field]; !ok {
+			doc[field] = nil
+		}
 	}
+	return doc, nil
+}
 
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found!!!"})
-		return
+// patchField แปลง JSON Pointer เป็นชื่อ field
+// book เป็น object ชั้นเดียว จึงรองรับเฉพาะ path ระดับบนสุด เช่น /price
+func patchField(pointer string) (string, error) {
+	if !strings.HasPrefix(pointer, "/") || strings.Count(pointer, "/") != 1 {
+		return "", fmt.Errorf("unsupported path %q", pointer)
+	}
+	field := strings.NewReplacer("~1", "/", "~0", "~").Replace(pointer[1:])
+	if err := checkPatchField(field); err != nil {
+		return "", err
 	}
+	return field, nil
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "book deleted successfully"})
+// applyMergePatch ใช้ RFC 7396 กับ doc คืนชื่อ field ที่ถูกแก้
+// ค่า null ใน patch หมายถึงตั้งคอลัมน์เป็น NULL
+func applyMergePatch(doc map[string]interface{}, body []byte) ([]string, error) {
+	var patch map[string]interface{}
+	if err := json.Unmarshal(body, &patch); err != nil || patch == nil {
+		return nil, errors.New("merge patch must be a JSON object")
+	}
+	var touched []string
+	for field, value := range patch {
+		if err := checkPatchField(field); err != nil {
+			return nil, err
+		}
+		doc[field] = value
+		touched = append(touched, field)
+	}
+	return touched, nil
 }
 
-// @Summary     Get all categories
-// @Description Get list of all book categories
-// @Tags        Books
-// @Accept      json
-// @Produce     json
-// @Success     200  {array}   string
-// @Failure     500  {object}  ErrorResponse
-// @Router      /categories [get]
-func getCategories(c *gin.Context) {
-	rows, err := db.Query(`
-        SELECT DISTINCT category 
-        FROM books 
-        WHERE category IS NOT NULL AND category != ''
-        ORDER BY category
-    `)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+// applyJSONPatch ใช้ RFC 6902 กับ doc ตามลำดับ operation คืนชื่อ field ที่ถูกแก้
+// "remove" ตั้งคอลัมน์เป็น NULL เพราะ book มี field ครบทุกตัวเสมอ
+func applyJSONPatch(doc map[string]interface{}, body []byte) ([]string, error) {
+	var ops []jsonPatchOp
+	if err := json.Unmarshal(body, &ops); err != nil {
+		return nil, errors.New("JSON patch must be an array of operations")
 	}
-	defer rows.Close()
 
-	var categories []string
-	for rows.Next() {
-		var category string
-		if err := rows.Scan(&category); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+	touched := map[string]bool{}
+	for i, op := range ops {
+		field, err := patchField(op.Path)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %v", i, err)
+		}
+
+		var value interface{}
+		if op.Op == "add" || op.Op == "replace" || op.Op == "test" {
+			if op.Value == nil {
+				return nil, fmt.Errorf("operation %d: value is required", i)
+			}
+			if err := json.Unmarshal(*op.Value, &value); err != nil {
+				return nil, fmt.Errorf("operation %d: %v", i, err)
+			}
+		}
+
+		switch op.Op {
+		case "add", "replace":
+			doc[field] = value
+			touched[field] = true
+		case "remove":
+			doc[field] = nil
+			touched[field] = true
+		case "move", "copy":
+			from, err := patchField(op.From)
+			if err != nil {
+				return nil, fmt.Errorf("operation %d: %v", i, err)
+			}
+			doc[field] = doc[from]
+			touched[field] = true
+			if op.Op == "move" && from != field {
+				doc[from] = nil
+				touched[from] = true
+			}
+		case "test":
+			if !reflect.DeepEqual(doc[field], value) {
+				return nil, fmt.Errorf("%w: %s", errPatchTestFailed, op.Path)
+			}
+		default:
+			return nil, fmt.Errorf("operation %d: unsupported op %q", i, op.Op)
 		}
-		categories = append(categories, category)
 	}
 
-	if categories == nil {
-		categories = []string{}
+	fields := make([]string, 0, len(touched))
+	for field := range touched {
+		fields = append(fields, field)
 	}
-	c.JSON(http.StatusOK, categories)
+	return fields, nil
 }
 
-// @Summary     Search books
-// @Description Search books by title, author, or description
+// @Summary     Partially update a book
+// @Description Update only the fields present in the request.
+// @Description Content-Type application/merge-patch+json (or application/json) applies an RFC 7396 merge patch;
+// @Description application/json-patch+json applies an RFC 6902 JSON patch. pages may be set to null.
+// @Description original_price, sale_price and discount are computed from promotions and cannot be patched.
 // @Tags        Books
 // @Accept      json
 // @Produce     json
-// @Param       q  query    string  true  "Search keyword"
-// @Success     200  {array}   Book
+// @Param       id        path    int     true   "Book ID"
+// @Param       If-Match  header  string  false  "ETag of the version being patched"
+// @Param       patch     body    object  true   "Merge patch object or JSON patch array"
+// @Success     200  {object}  Book
 // @Failure     400  {object}  ErrorResponse
+// @Failure     404  {object}  ErrorResponse
+// @Failure     409  {object}  ErrorResponse
+// @Failure     412  {object}  ErrorResponse
+// @Failure     415  {object}  ErrorResponse
+// @Failure     428  {object}  ErrorResponse
 // @Failure     500  {object}  ErrorResponse
-// @Router      /books/search [get]
-func searchBooks(c *gin.Context) {
-	keyword := c.Query("q")
-	if keyword == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "search keyword is required"})
+// @Router      /books/{id} [patch]
+func patchBook(c *gin.Context) {
+	id := c.Param("id")
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	searchPattern := "%" + keyword + "%"
-	rows, err := db.Query(`
-        SELECT id, title, author, isbn, year, price,
-               category, original_price, discount, cover_image,
-               rating, reviews_count, is_new, pages,
-               language, publisher, description,
-               created_at, updated_at
-        FROM books
-        WHERE title ILIKE $1 OR author ILIKE $1 OR description ILIKE $1
-        ORDER BY rating DESC, title
-    `, searchPattern)
+	var apply func(map[string]interface{}, []byte) ([]string, error)
+	switch c.ContentType() {
+	case "application/merge-patch+json", "application/json":
+		apply = applyMergePatch
+	case "application/json-patch+json":
+		apply = applyJSONPatch
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "use application/merge-patch+json or application/json-patch+json"})
+		return
+	}
+	if !checkIfMatchPresent(c) {
+		return
+	}
 
+	tx, err := db.Begin()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	defer rows.Close()
+	defer tx.Rollback()
 
-	var books []Book
-	for rows.Next() {
-		var book Book
-		err := rows.Scan(
-			&book.ID, &book.Title, &book.Author, &book.ISBN, &book.Year, &book.Price,
-			&book.Category, &book.OriginalPrice, &book.Discount, &book.CoverImage,
-			&book.Rating, &book.ReviewsCount, &book.IsNew, &book.Pages,
-			&book.Language, &book.Publisher, &book.Description,
-			&book.CreatedAt, &book.UpdatedAt,
-		)
-		if err != nil {
+	// ล็อกแถวไว้จนจบ transaction เพื่อไม่ให้ patch สองตัวอ่านค่าเดิมพร้อมกันแล้วทับกัน
+	current, err := lockBook(tx, id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "book not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkIfMatch(c, current) {
+		return
+	}
+
+	doc, err := bookDocument(current)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	touched, err := apply(doc, body)
+	if errors.Is(err, errPatchTestFailed) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(touched) == 0 {
+		if err := attachAuthors(tx, &current); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		books = append(books, book)
+		c.Header("ETag", bookETag(current))
+		c.JSON(http.StatusOK, current)
+		return
 	}
 
-	if books == nil {
-		books = []Book{}
+	for _, field := range touched {
+		if doc[field] == nil && !bookPatchColumns[field].nullable {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s cannot be null", field)})
+			return
+		}
 	}
-	c.JSON(http.StatusOK, books)
-}
-
-// @Summary     Get featured books
-// @Description Get books with high ratings (4.0+)
-// @Tags        Books
-// @Accept      json
-// @Produce     json
-// @Param       limit  query    int  false  "Number of books to return (default 10)"
-// @Success     200  {array}   Book
-// @Failure     500  {object}  ErrorResponse
-// @Router      /books/featured [get]
-func getFeaturedBooks(c *gin.Context) {
-	limit := c.DefaultQuery("limit", "10")
-
-	rows, err := db.Query(`
-        SELECT id, title, author, isbn, year, price,
-               category, original_price, discount, cover_image,
-               rating, reviews_count, is_new, pages,
-               language, publisher, description,
-               created_at, updated_at
-        FROM books
-        WHERE rating >= 4.0
-        ORDER BY rating DESC, reviews_count DESC
-        LIMIT $1
-    `, limit)
 
+	// แปลงกลับเป็น Book เพื่อตรวจชนิดข้อมูลของแต่ละ field
+	data, err := json.Marshal(doc)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	defer rows.Close()
+	var patched Book
+	if err := json.Unmarshal(data, &patched); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	var books []Book
-	for rows.Next() {
-		var book Book
-		err := rows.Scan(
-			&book.ID, &book.Title, &book.Author, &book.ISBN, &book.Year, &book.Price,
-			&book.Category, &book.OriginalPrice, &book.Discount, &book.CoverImage,
-			&book.Rating, &book.ReviewsCount, &book.IsNew, &book.Pages,
-			&book.Language, &book.Publisher, &book.Description,
-			&book.CreatedAt, &book.UpdatedAt,
-		)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		books = append(books, book)
+	sort.Strings(touched)
+	sets := make([]string, len(touched))
+	args := make([]interface{}, len(touched), len(touched)+1)
+	for i, field := range touched {
+		sets[i] = fmt.Sprintf("%s = $%d", field, i+1)
+		args[i] = bookPatchColumns[field].value(&patched)
 	}
+	args = append(args, id)
 
-	if books == nil {
-		books = []Book{}
+	updated, err := scanBook(tx.QueryRow(returningBook(fmt.Sprintf(`
+        UPDATE books SET %s
+        WHERE id = $%d`, strings.Join(sets, ", "), len(args))), args...))
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "a book with this isbn already exists"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
-	c.JSON(http.StatusOK, books)
+	if err := attachAuthors(tx, &updated); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("ETag", bookETag(updated))
+	c.JSON(http.StatusOK, updated)
 }
 
-// @Summary     Get discounted books
-// @Description Get books with discount greater than 0
+// @Summary     Delete a book
+// @Description Move book to the trash (soft delete)
 // @Tags        Books
 // @Accept      json
 // @Produce     json
-// @Success     200  {array}   Book
+// @Param       id        path      int     true   "Book ID"
+// @Param       If-Match  header    string  false  "ETag of the version being deleted"
+// @Success     200  {object}  map[string]interface{}
+// @Failure     404  {object}  ErrorResponse
+// @Failure     412  {object}  ErrorResponse
+// @Failure     428  {object}  ErrorResponse
 // @Failure     500  {object}  ErrorResponse
-// @Router      /books/discounted [get]
-func getDiscountedBooks(c *gin.Context) {
-	rows, err := db.Query(`
-        SELECT id, title, author, isbn, year, price,
-               category, original_price, discount, cover_image,
-               rating, reviews_count, is_new, pages,
-               language, publisher, description,
-               created_at, updated_at
-        FROM books
-        WHERE discount > 0
-        ORDER BY discount DESC, rating DESC
-    `)
+// @Router      /books/{id} [delete]
+func deleteBook(c *gin.Context) {
+	id := c.Param("id")
+
+	if !checkIfMatchPresent(c) {
+		return
+	}
 
+	tx, err := db.Begin()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error message": err.Error()})
 		return
 	}
-	defer rows.Close()
+	defer tx.Rollback()
 
-	var books []Book
-	for rows.Next() {
-		var book Book
-		err := rows.Scan(
-			&book.ID, &book.Title, &book.Author, &book.ISBN, &book.Year, &book.Price,
-			&book.Category, &book.OriginalPrice, &book.Discount, &book.CoverImage,
-			&book.Rating, &book.ReviewsCount, &book.IsNew, &book.Pages,
-			&book.Language, &book.Publisher, &book.Description,
-			&book.CreatedAt, &book.UpdatedAt,
-		)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		books = append(books, book)
+	current, err := lockBook(tx, id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found!!!"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error message": err.Error()})
+		return
+	}
+	if !checkIfMatch(c, current) {
+		return
 	}
 
-	if books == nil {
-		books = []Book{}
+	// soft delete: หนังสือย้ายไปถังขยะ การดู กู้คืน และลบถาวรทำผ่าน API ของ week13-lab6 (ต้องมีสิทธิ์)
+	if _, err := tx.Exec("UPDATE books SET deleted_at = NOW() WHERE id = $1", id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error message": err.Error()})
+		return
 	}
-	c.JSON(http.StatusOK, books)
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "book moved to trash"})
 }
 
-// @title           Simple API Example
-// @version         1.0
-// @description     This is a simple example of using Gin with Swagger.
-// @host            localhost:8080
-// @BasePath        /api/v1
-func main() {
-	initDB()
-	defer db.Close()
+// AuthorRef คือผู้แต่งที่แนบมากับ Book เรียงตามลำดับที่เขียนใน author
+type AuthorRef struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type Author struct {
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	Bio        string    `json:"bio"`
+	BooksCount int       `json:"books_count"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type AuthorRequest struct {
+	Name string `json:"name" binding:"required,max=255"`
+	Bio  string `json:"bio"`
+}
+
+type Publisher struct {
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	BooksCount int       `json:"books_count"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type PublisherRequest struct {
+	Name string `json:"name" binding:"required,max=255"`
+}
+
+type Category struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Slug        string    `json:"slug"`
+	Description string    `json:"description"`
+	BooksCount  int       `json:"books_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// CategoryRequest: slug ว่างหมายถึงสร้างจากชื่อ (ตอนสร้าง) หรือใช้ค่าเดิม (ตอนแก้ไข)
+type CategoryRequest struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	Slug        string `json:"slug" binding:"max=120"`
+	Description string `json:"description"`
+}
+
+// books_count นับเฉพาะหนังสือที่ไม่อยู่ในถังขยะ
+const authorSelectQuery = `
+    SELECT a.id, a.name, a.bio,
+           (SELECT COUNT(*) FROM book_authors ba JOIN books b ON b.id = ba.book_id
+            WHERE ba.author_id = a.id AND b.deleted_at IS NULL),
+           a.created_at, a.updated_at
+    FROM authors a`
+
+const publisherSelectQuery = `
+    SELECT p.id, p.name,
+           (SELECT COUNT(*) FROM books b WHERE b.publisher_id = p.id AND b.deleted_at IS NULL),
+           p.created_at, p.updated_at
+    FROM publishers p`
+
+const categorySelectQuery = `
+    SELECT g.id, g.name, g.slug, g.description,
+           (SELECT COUNT(*) FROM books b WHERE b.category_id = g.id AND b.deleted_at IS NULL),
+           g.created_at, g.updated_at
+    FROM categories g`
+
+const maxTaxonomyPageSize = 100
+
+// categorySlugPattern: ตัวพิมพ์เล็ก/ตัวเลข/ตัวอักษรภาษาอื่น คั่นคำด้วย - ตัวเดียว
+var categorySlugPattern = regexp.MustCompile(`^[\p{Ll}\p{Lo}\p{M}\p{N}]+(-[\p{Ll}\p{Lo}\p{M}\p{N}]+)*$`)
+
+func scanAuthor(row rowScanner) (Author, error) {
+	var a Author
+	err := row.Scan(&a.ID, &a.Name, &a.Bio, &a.BooksCount, &a.CreatedAt, &a.UpdatedAt)
+	return a, err
+}
+
+func scanPublisher(row rowScanner) (Publisher, error) {
+	var p Publisher
+	err := row.Scan(&p.ID, &p.Name, &p.BooksCount, &p.CreatedAt, &p.UpdatedAt)
+	return p, err
+}
+
+func scanCategory(row rowScanner) (Category, error) {
+	var g Category
+	err := row.Scan(&g.ID, &g.Name, &g.Slug, &g.Description, &g.BooksCount, &g.CreatedAt, &g.UpdatedAt)
+	return g, err
+}
+
+// isForeignKeyViolation ตรวจว่า error มาจาก foreign key (เช่นลบ author ที่ยังมีหนังสือ)
+func isForeignKeyViolation(err error) bool {
+	if pqErr, ok := err.(*pq.Error); ok {
+		return pqErr.Code == "23503"
+	}
+	return false
+}
+
+// parseIntParam อ่าน path parameter ที่เป็นตัวเลข ตอบ 400 ถ้าไม่ใช่
+func parseIntParam(c *gin.Context, name string) (int, bool) {
+	id, err := strconv.Atoi(c.Param(name))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name})
+		return 0, false
+	}
+	return id, true
+}
+
+// parseTaxonomyListQuery อ่าน q, limit และ offset ของ /authors และ /publishers
+// คืนเงื่อนไข WHERE (ค้นชื่อแบบไม่สนตัวพิมพ์) พร้อม args
+func parseTaxonomyListQuery(c *gin.Context, alias string) (string, []interface{}, error) {
+	limit, offset := 50, 0
+	if s := c.Query("limit"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil || v < 1 || v > maxTaxonomyPageSize {
+			return "", nil, fmt.Errorf("limit must be between 1 and %d", maxTaxonomyPageSize)
+		}
+		limit = v
+	}
+	if s := c.Query("offset"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil || v < 0 {
+			return "", nil, errors.New("offset must not be negative")
+		}
+		offset = v
+	}
+
+	where := ""
+	args := []interface{}{}
+	if s := strings.TrimSpace(c.Query("q")); s != "" {
+		args = append(args, "%"+s+"%")
+		where = " WHERE " + alias + ".name ILIKE $1"
+	}
+	return fmt.Sprintf("%s ORDER BY lower(%s.name), %s.id LIMIT %d OFFSET %d", where, alias, alias, limit, offset), args, nil
+}
+
+// validateAuthorName ตรวจว่าชื่อแยกแล้วได้ผู้แต่งคนเดียว
+// ใช้กฎเดียวกับ split_author_names ในฐานข้อมูล ไม่อย่างนั้น trigger จะแยกชื่อนี้เป็นหลายคน
+func validateAuthorName(q rowQueryer, name string) (bool, error) {
+	var count int
+	err := q.QueryRow("SELECT COUNT(*) FROM split_author_names($1)", name).Scan(&count)
+	return count == 1, err
+}
+
+// rowQueryer คือส่วนที่ *sql.DB และ *sql.Tx มีร่วมกันสำหรับ query แถวเดียว
+type rowQueryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// @Summary     List authors
+// @Description List authors ordered by name
+// @Tags        Authors
+// @Produce     json
+// @Param       q       query  string  false  "Filter by name (case insensitive, partial match)"
+// @Param       limit   query  int     false  "Page size (default 50, max 100)"
+// @Param       offset  query  int     false  "Number of authors to skip"
+// @Success     200  {array}   Author
+// @Failure     400  {object}  ErrorResponse
+// @Failure     500  {object}  ErrorResponse
+// @Router      /authors [get]
+func getAuthors(c *gin.Context) {
+	tail, args, err := parseTaxonomyListQuery(c, "a")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rows, err := db.Query(authorSelectQuery+tail, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	authors := []Author{}
+	for rows.Next() {
+		author, err := scanAuthor(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		authors = append(authors, author)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, authors)
+}
+
+// @Summary     Get author by ID
+// @Tags        Authors
+// @Produce     json
+// @Param       id   path      int  true  "Author ID"
+// @Success     200  {object}  Author
+// @Failure     400  {object}  ErrorResponse
+// @Failure     404  {object}  ErrorResponse
+// @Failure     500  {object}  ErrorResponse
+// @Router      /authors/{id} [get]
+func getAuthor(c *gin.Context) {
+	id, ok := parseIntParam(c, "id")
+	if !ok {
+		return
+	}
+
+	author, err := scanAuthor(db.QueryRow(authorSelectQuery+" WHERE a.id = $1", id))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "author not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, author)
+}
+
+// @Summary     Create an author
+// @Description The name must be a single author, it cannot contain , ; & or "and"
+// @Tags        Authors
+// @Accept      json
+// @Produce     json
+// @Param       author  body      AuthorRequest  true  "Author"
+// @Success     201  {object}  Author
+// @Failure     400  {object}  ErrorResponse
+// @Failure     409  {object}  ErrorResponse
+// @Failure     500  {object}  ErrorResponse
+// @Router      /authors [post]
+func createAuthor(c *gin.Context) {
+	var req AuthorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+
+	single, err := validateAuthorName(db, req.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !single {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name must be a single author"})
+		return
+	}
+
+	var id int
+	err = db.QueryRow("INSERT INTO authors (name, bio) VALUES ($1, $2) RETURNING id", req.Name, req.Bio).Scan(&id)
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "an author with this name already exists"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	author, err := scanAuthor(db.QueryRow(authorSelectQuery+" WHERE a.id = $1", id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, author)
+}
+
+// @Summary     Update an author
+// @Description Renaming an author also rewrites the author text of every book by that author
+// @Tags        Authors
+// @Accept      json
+// @Produce     json
+// @Param       id      path      int            true  "Author ID"
+// @Param       author  body      AuthorRequest  true  "Author"
+// @Success     200  {object}  Author
+// @Failure     400  {object}  ErrorResponse
+// @Failure     404  {object}  ErrorResponse
+// @Failure     409  {object}  ErrorResponse
+// @Failure     500  {object}  ErrorResponse
+// @Router      /authors/{id} [put]
+func updateAuthor(c *gin.Context) {
+	id, ok := parseIntParam(c, "id")
+	if !ok {
+		return
+	}
+	var req AuthorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	single, err := validateAuthorName(tx, req.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !single {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name must be a single author"})
+		return
+	}
+
+	var oldName string
+	err = tx.QueryRow("SELECT name FROM authors WHERE id = $1 FOR UPDATE", id).Scan(&oldName)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "author not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, err = tx.Exec("UPDATE authors SET name = $1, bio = $2 WHERE id = $3", req.Name, req.Bio, id)
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "an author with this name already exists"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// เขียนข้อความ author ของหนังสือใหม่จากรายชื่อผู้แต่ง (คั่นด้วย ", ")
+	// trigger จะแยกชื่อกลับมาเป็นผู้แต่งชุดเดิมเพราะชื่อใหม่ตรงกับแถวนี้แล้ว
+	if req.Name != oldName {
+		_, err = tx.Exec(`
+            UPDATE books b SET author = (
+                SELECT string_agg(a.name, ', ' ORDER BY ba.position)
+                FROM book_authors ba JOIN authors a ON a.id = ba.author_id
+                WHERE ba.book_id = b.id
+            )
+            WHERE b.id IN (SELECT book_id FROM book_authors WHERE author_id = $1)`, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	author, err := scanAuthor(tx.QueryRow(authorSelectQuery+" WHERE a.id = $1", id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, author)
+}
+
+// @Summary     Delete an author
+// @Description Only authors without books (including books in the trash) can be deleted
+// @Tags        Authors
+// @Produce     json
+// @Param       id   path      int  true  "Author ID"
+// @Success     200  {object}  map[string]interface{}
+// @Failure     400  {object}  ErrorResponse
+// @Failure     404  {object}  ErrorResponse
+// @Failure     409  {object}  ErrorResponse
+// @Failure     500  {object}  ErrorResponse
+// @Router      /authors/{id} [delete]
+func deleteAuthor(c *gin.Context) {
+	id, ok := parseIntParam(c, "id")
+	if !ok {
+		return
+	}
+
+	result, err := db.Exec("DELETE FROM authors WHERE id = $1", id)
+	if isForeignKeyViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "author still has books"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "author not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "author deleted successfully"})
+}
+
+// @Summary     Get books by author
+// @Description Books of one author with the same paging, sorting and filters as /books
+// @Tags        Authors
+// @Produce     json
+// @Param       id      path   int     true   "Author ID"
+// @Param       limit   query  int     false  "Page size (default 20, max 100)"
+// @Param       cursor  query  string  false  "Opaque cursor from next_cursor of the previous page"
+// @Param       sort    query  string  false  "Comma separated sort keys (default -year)"
+// @Param       fields  query  string  false  "Comma separated fields to return"
+// @Success     200  {object}  BookListResponse
+// @Failure     400  {object}  ErrorResponse
+// @Failure     404  {object}  ErrorResponse
+// @Failure     500  {object}  ErrorResponse
+// @Router      /authors/{id}/books [get]
+func getAuthorBooks(c *gin.Context) {
+	id, ok := parseIntParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM authors WHERE id = $1)", id).Scan(&exists); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "author not found"})
+		return
+	}
+
+	q, err := parseBookListQuery(c, "-year", 20)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	q.where("id IN (SELECT book_id FROM book_authors WHERE author_id = ?)", id)
+
+	listBooks(c, q)
+}
+
+// @Summary     List publishers
+// @Description List publishers ordered by name
+// @Tags        Publishers
+// @Produce     json
+// @Param       q       query  string  false  "Filter by name (case insensitive, partial match)"
+// @Param       limit   query  int     false  "Page size (default 50, max 100)"
+// @Param       offset  query  int     false  "Number of publishers to skip"
+// @Success     200  {array}   Publisher
+// @Failure     400  {object}  ErrorResponse
+// @Failure     500  {object}  ErrorResponse
+// @Router      /publishers [get]
+func getPublishers(c *gin.Context) {
+	tail, args, err := parseTaxonomyListQuery(c, "p")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rows, err := db.Query(publisherSelectQuery+tail, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	publishers := []Publisher{}
+	for rows.Next() {
+		publisher, err := scanPublisher(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		publishers = append(publishers, publisher)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, publishers)
+}
+
+// @Summary     Get publisher by ID
+// @Tags        Publishers
+// @Produce     json
+// @Param       id   path      int  true  "Publisher ID"
+// @Success     200  {object}  Publisher
+// @Failure     400  {object}  ErrorResponse
+// @Failure     404  {object}  ErrorResponse
+// @Failure     500  {object}  ErrorResponse
+// @Router      /publishers/{id} [get]
+func getPublisher(c *gin.Context) {
+	id, ok := parseIntParam(c, "id")
+	if !ok {
+		return
+	}
+
+	publisher, err := scanPublisher(db.QueryRow(publisherSelectQuery+" WHERE p.id = $1", id))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "publisher not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, publisher)
+}
+
+// @Summary     Create a publisher
+// @Tags        Publishers
+// @Accept      json
+// @Produce     json
+// @Param       publisher  body      PublisherRequest  true  "Publisher"
+// @Success     201  {object}  Publisher
+// @Failure     400  {object}  ErrorResponse
+// @Failure     409  {object}  ErrorResponse
+// @Failure     500  {object}  ErrorResponse
+// @Router      /publishers [post]
+func createPublisher(c *gin.Context) {
+	var req PublisherRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	var id int
+	err := db.QueryRow("INSERT INTO publishers (name) VALUES ($1) RETURNING id", req.Name).Scan(&id)
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "a publisher with this name already exists"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	publisher, err := scanPublisher(db.QueryRow(publisherSelectQuery+" WHERE p.id = $1", id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, publisher)
+}
+
+// @Summary     Update a publisher
+// @Description Renaming a publisher also rewrites the publisher text of its books
+// @Tags        Publishers
+// @Accept      json
+// @Produce     json
+// @Param       id         path      int               true  "Publisher ID"
+// @Param       publisher  body      PublisherRequest  true  "Publisher"
+// @Success     200  {object}  Publisher
+// @Failure     400  {object}  ErrorResponse
+// @Failure     404  {object}  ErrorResponse
+// @Failure     409  {object}  ErrorResponse
+// @Failure     500  {object}  ErrorResponse
+// @Router      /publishers/{id} [put]
+func updatePublisher(c *gin.Context) {
+	id, ok := parseIntParam(c, "id")
+	if !ok {
+		return
+	}
+	var req PublisherRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE publishers SET name = $1 WHERE id = $2", req.Name, id)
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "a publisher with this name already exists"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "publisher not found"})
+		return
+	}
+
+	// trigger ของ books จะผูก publisher_id กลับมาที่แถวเดิมเพราะชื่อตรงกันแล้ว
+	if _, err := tx.Exec("UPDATE books SET publisher = $1 WHERE publisher_id = $2 AND publisher <> $1", req.Name, id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	publisher, err := scanPublisher(tx.QueryRow(publisherSelectQuery+" WHERE p.id = $1", id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, publisher)
+}
+
+// @Summary     Delete a publisher
+// @Description Only publishers without books (including books in the trash) can be deleted
+// @Tags        Publishers
+// @Produce     json
+// @Param       id   path      int  true  "Publisher ID"
+// @Success     200  {object}  map[string]interface{}
+// @Failure     400  {object}  ErrorResponse
+// @Failure     404  {object}  ErrorResponse
+// @Failure     409  {object}  ErrorResponse
+// @Failure     500  {object}  ErrorResponse
+// @Router      /publishers/{id} [delete]
+func deletePublisher(c *gin.Context) {
+	id, ok := parseIntParam(c, "id")
+	if !ok {
+		return
+	}
+
+	result, err := db.Exec("DELETE FROM publishers WHERE id = $1", id)
+	if isForeignKeyViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "publisher still has books"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "publisher not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "publisher deleted successfully"})
+}
+
+// @Summary     Get books by publisher
+// @Description Books of one publisher with the same paging, sorting and filters as /books
+// @Tags        Publishers
+// @Produce     json
+// @Param       id      path   int     true   "Publisher ID"
+// @Param       limit   query  int     false  "Page size (default 20, max 100)"
+// @Param       cursor  query  string  false  "Opaque cursor from next_cursor of the previous page"
+// @Param       sort    query  string  false  "Comma separated sort keys (default -year)"
+// @Param       fields  query  string  false  "Comma separated fields to return"
+// @Success     200  {object}  BookListResponse
+// @Failure     400  {object}  ErrorResponse
+// @Failure     404  {object}  ErrorResponse
+// @Failure     500  {object}  ErrorResponse
+// @Router      /publishers/{id}/books [get]
+func getPublisherBooks(c *gin.Context) {
+	id, ok := parseIntParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM publishers WHERE id = $1)", id).Scan(&exists); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "publisher not found"})
+		return
+	}
+
+	q, err := parseBookListQuery(c, "-year", 20)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	q.where("publisher_id = ?", id)
+
+	listBooks(c, q)
+}
+
+// @Summary     Get all categories
+// @Description Get list of all book categories with the number of books in each
+// @Tags        Categories
+// @Accept      json
+// @Produce     json
+// @Success     200  {array}   Category
+// @Failure     500  {object}  ErrorResponse
+// @Router      /categories [get]
+func getCategories(c *gin.Context) {
+	rows, err := db.Query(categorySelectQuery + " ORDER BY lower(g.name), g.id")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	categories := []Category{}
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		categories = append(categories, category)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, categories)
+}
+
+// @Summary     Get category by slug
+// @Tags        Categories
+// @Produce     json
+// @Param       slug  path      string  true  "Category slug"
+// @Success     200  {object}  Category
+// @Failure     404  {object}  ErrorResponse
+// @Failure     500  {object}  ErrorResponse
+// @Router      /categories/{slug} [get]
+func getCategory(c *gin.Context) {
+	category, err := scanCategory(db.QueryRow(categorySelectQuery+" WHERE g.slug = $1", c.Param("slug")))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// bindCategoryRequest อ่านและตรวจ CategoryRequest ตอบ 400 ถ้าไม่ถูกต้อง
+func bindCategoryRequest(c *gin.Context) (CategoryRequest, bool) {
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, false
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	req.Slug = strings.TrimSpace(req.Slug)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return req, false
+	}
+	if req.Slug != "" && !categorySlugPattern.MatchString(req.Slug) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "slug must be lowercase words separated by single hyphens"})
+		return req, false
+	}
+	return req, true
+}
+
+// @Summary     Create a category
+// @Description slug is generated from the name when omitted
+// @Tags        Categories
+// @Accept      json
+// @Produce     json
+// @Param       category  body      CategoryRequest  true  "Category"
+// @Success     201  {object}  Category
+// @Failure     400  {object}  ErrorResponse
+// @Failure     409  {object}  ErrorResponse
+// @Failure     500  {object}  ErrorResponse
+// @Router      /categories [post]
+func createCategory(c *gin.Context) {
+	req, ok := bindCategoryRequest(c)
+	if !ok {
+		return
+	}
+
+	var slug string
+	err := db.QueryRow(`
+        INSERT INTO categories (name, slug, description)
+        VALUES ($1::text, COALESCE(NULLIF($2, ''), category_slug($1::text)), $3)
+        RETURNING slug`, req.Name, req.Slug, req.Description).Scan(&slug)
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "a category with this name or slug already exists"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	category, err := scanCategory(db.QueryRow(categorySelectQuery+" WHERE g.slug = $1", slug))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, category)
+}
+
+// @Summary     Update a category
+// @Description Renaming a category also rewrites the category text of its books. An empty slug keeps the current one.
+// @Tags        Categories
+// @Accept      json
+// @Produce     json
+// @Param       slug      path      string           true  "Category slug"
+// @Param       category  body      CategoryRequest  true  "Category"
+// @Success     200  {object}  Category
+// @Failure     400  {object}  ErrorResponse
+// @Failure     404  {object}  ErrorResponse
+// @Failure     409  {object}  ErrorResponse
+// @Failure     500  {object}  ErrorResponse
+// @Router      /categories/{slug} [put]
+func updateCategory(c *gin.Context) {
+	req, ok := bindCategoryRequest(c)
+	if !ok {
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	var id int
+	var slug string
+	err = tx.QueryRow(`
+        UPDATE categories
+        SET name = $1, slug = COALESCE(NULLIF($2, ''), slug), description = $3
+        WHERE slug = $4
+        RETURNING id, slug`, req.Name, req.Slug, req.Description, c.Param("slug")).Scan(&id, &slug)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
+		return
+	} else if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "a category with this name or slug already exists"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := tx.Exec("UPDATE books SET category = $1 WHERE category_id = $2 AND category <> $1", req.Name, id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	category, err := scanCategory(tx.QueryRow(categorySelectQuery+" WHERE g.id = $1", id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// @Summary     Delete a category
+// @Description Only categories without books (including books in the trash) can be deleted
+// @Tags        Categories
+// @Produce     json
+// @Param       slug  path      string  true  "Category slug"
+// @Success     200  {object}  map[string]interface{}
+// @Failure     404  {object}  ErrorResponse
+// @Failure     409  {object}  ErrorResponse
+// @Failure     500  {object}  ErrorResponse
+// @Router      /categories/{slug} [delete]
+func deleteCategory(c *gin.Context) {
+	result, err := db.Exec("DELETE FROM categories WHERE slug = $1", c.Param("slug"))
+	if isForeignKeyViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "category still has books"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "category deleted successfully"})
+}
+
+// @Summary     Get books in a category
+// @Description Books of one category with the same paging, sorting and filters as /books
+// @Tags        Categories
+// @Produce     json
+// @Param       slug    path   string  true   "Category slug"
+// @Param       limit   query  int     false  "Page size (default 20, max 100)"
+// @Param       cursor  query  string  false  "Opaque cursor from next_cursor of the previous page"
+// @Param       sort    query  string  false  "Comma separated sort keys (default -created_at)"
+// @Param       fields  query  string  false  "Comma separated fields to return"
+// @Success     200  {object}  BookListResponse
+// @Failure     400  {object}  ErrorResponse
+// @Failure     404  {object}  ErrorResponse
+// @Failure     500  {object}  ErrorResponse
+// @Router      /categories/{slug}/books [get]
+func getCategoryBooks(c *gin.Context) {
+	var id int
+	err := db.QueryRow("SELECT id FROM categories WHERE slug = $1", c.Param("slug")).Scan(&id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	q, err := parseBookListQuery(c, "-created_at", 20)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	q.where("category_id = ?", id)
+
+	listBooks(c, q)
+}
+
+// headlineOptions คือตัวเลือกของ ts_headline ที่ใช้ครอบคำที่ตรงกับคำค้น
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>"
+
+// buildSearchTSQuery แปลงคำค้นเป็น tsquery expression
+// คำที่ลงท้ายด้วย * (นอกเครื่องหมายคำพูด) เป็น prefix match ส่วนที่เหลือส่งให้ websearch_to_tsquery
+// ซึ่งรองรับ "phrase", OR และ -คำ อยู่แล้ว คืนค่าว่างถ้าไม่เหลือคำให้ค้น
+func buildSearchTSQuery(q *bookListQuery, keyword string) string {
+	var rest []string
+	var parts []string
+	inQuote := false
+	for _, word := range strings.Fields(keyword) {
+		quotes := strings.Count(word, `"`)
+		if !inQuote && quotes == 0 && len(word) > 1 && strings.HasSuffix(word, "*") {
+			prefix := strings.Map(func(r rune) rune {
+				if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) {
+					return r
+				}
+				return -1
+			}, word)
+			if prefix != "" {
+				parts = append(parts, "to_tsquery(books_search_config(), "+q.arg(prefix+":*")+")")
+			}
+			continue
+		}
+		if quotes%2 == 1 {
+			inQuote = !inQuote
+		}
+		rest = append(rest, word)
+	}
+	if len(rest) > 0 {
+		parts = append(parts, "websearch_to_tsquery(books_search_config(), "+q.arg(strings.Join(rest, " "))+")")
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "(" + strings.Join(parts, " && ") + ")"
+}
+
+// applyBookSearch จำกัดผลลัพธ์ของ q ให้ตรงกับคำค้น คืน false ถ้าไม่เหลือคำให้ค้น
+func applyBookSearch(q *bookListQuery, keyword string) bool {
+	q.tsquery = buildSearchTSQuery(q, keyword)
+	if q.tsquery == "" {
+		return false
+	}
+	q.where("search_vector @@ " + q.tsquery)
+	return true
+}
+
+// @Summary     Search books
+// @Description Full-text search over title, author and description ranked by relevance.
+// @Description Supports "quoted phrases", OR, -exclusion and prefix* terms. Each hit carries highlighted snippets.
+// @Tags        Books
+// @Accept      json
+// @Produce     json
+// @Param       q          query  string  true   "Search keyword"
+// @Param       limit      query  int     false  "Page size (default 20, max 100)"
+// @Param       cursor     query  string  false  "Opaque cursor from next_cursor of the previous page"
+// @Param       sort       query  string  false  "Comma separated sort keys (default -relevance)"
+// @Param       fields     query  string  false  "Comma separated fields to return"
+// @Param       year       query  int     false  "Filter by year"
+// @Param       category   query  string  false  "Filter by category"
+// @Param       language   query  string  false  "Filter by language"
+// @Param       publisher  query  string  false  "Filter by publisher"
+// @Param       author_id  query  int     false  "Filter by author ID"
+// @Param       publisher_id  query  int  false  "Filter by publisher ID"
+// @Param       category_id   query  int  false  "Filter by category ID"
+// @Param       in_stock      query  bool false  "Only books that are (true) or are not (false) available"
+// @Param       min_price  query  number  false  "Minimum sale price"
+// @Param       max_price  query  number  false  "Maximum sale price"
+// @Param       min_year   query  int     false  "Minimum year"
+// @Param       max_year   query  int     false  "Maximum year"
+// @Success     200  {object}  BookListResponse
+// @Failure     400  {object}  ErrorResponse
+// @Failure     500  {object}  ErrorResponse
+// @Router      /books/search [get]
+func searchBooks(c *gin.Context) {
+	keyword := strings.TrimSpace(c.Query("q"))
+	if keyword == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "search keyword is required"})
+		return
+	}
+
+	q, err := parseBookListQuery(c, "-relevance", 20)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !applyBookSearch(q, keyword) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "search keyword is required"})
+		return
+	}
+
+	listBooks(c, q)
+}
+
+// FacetValue คือจำนวนหนังสือของค่าหนึ่งใน facet
+type FacetValue struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// BookFacetsResponse คือ response ของ /books/facets
+type BookFacetsResponse struct {
+	Total  int                     `json:"total"`
+	Facets map[string][]FacetValue `json:"facets"`
+}
+
+// facetBucket คือช่วง [min, max) ของ facet แบบช่วง max < 0 หมายถึงไม่มีขอบบน
+type facetBucket struct {
+	label string
+	min   float64
+	max   float64
+}
+
+var priceFacetBuckets = []facetBucket{
+	{"0-199", 0, 200},
+	{"200-399", 200, 400},
+	{"400-599", 400, 600},
+	{"600-999", 600, 1000},
+	{"1000+", 1000, -1},
+}
+
+var ratingFacetBuckets = []facetBucket{
+	{"4-5", 4, -1},
+	{"3-4", 3, 4},
+	{"2-3", 2, 3},
+	{"1-2", 1, 2},
+	{"0-1", 0, 1},
+}
+
+// bucketCase สร้าง CASE expression ที่แปลงค่าของ column เป็น label ของ bucket
+func bucketCase(column string, buckets []facetBucket) string {
+	var b strings.Builder
+	b.WriteString("CASE")
+	for _, bucket := range buckets {
+		if bucket.max < 0 {
+			fmt.Fprintf(&b, " WHEN %s >= %g THEN '%s'", column, bucket.min, bucket.label)
+		} else {
+			fmt.Fprintf(&b, " WHEN %s >= %g AND %s < %g THEN '%s'", column, bucket.min, column, bucket.max, bucket.label)
+		}
+	}
+	b.WriteString(" END")
+	return b.String()
+}
+
+// @Summary     Get facet counts
+// @Description Count books per category, year, language, publisher, price bucket and rating bucket.
+// @Description Accepts the same filters as /books and /books/search (including q).
+// @Description Each facet is counted with every filter except its own, so the other values of a selected facet stay visible.
+// @Description Price buckets use the sale price.
+// @Tags        Books
+// @Accept      json
+// @Produce     json
+// @Param       q          query  string  false  "Search keyword"
+// @Param       year       query  int     false  "Filter by year"
+// @Param       category   query  string  false  "Filter by category"
+// @Param       language   query  string  false  "Filter by language"
+// @Param       publisher  query  string  false  "Filter by publisher"
+// @Param       author_id  query  int     false  "Filter by author ID"
+// @Param       publisher_id  query  int  false  "Filter by publisher ID"
+// @Param       category_id   query  int  false  "Filter by category ID"
+// @Param       in_stock      query  bool false  "Only books that are (true) or are not (false) available"
+// @Param       min_price  query  number  false  "Minimum sale price"
+// @Param       max_price  query  number  false  "Maximum sale price"
+// @Param       min_year   query  int     false  "Minimum year"
+// @Param       max_year   query  int     false  "Maximum year"
+// @Success     200  {object}  BookFacetsResponse
+// @Failure     400  {object}  ErrorResponse
+// @Failure     500  {object}  ErrorResponse
+// @Router      /books/facets [get]
+func getBookFacets(c *gin.Context) {
+	q := &bookListQuery{}
+	if err := parseBookFilters(c, q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if keyword := strings.TrimSpace(c.Query("q")); keyword != "" {
+		if !applyBookSearch(q, keyword) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "search keyword is required"})
+			return
+		}
+	}
+
+	// facet แบบ disjunctive: แต่ละ facet นับจากแถวที่ผ่านทุก filter ยกเว้น filter ของ facet นั้นเอง
+	// ผู้ใช้จึงยังเห็นค่าอื่นของ facet ที่เลือกไว้ เงื่อนไขของ facet กลายเป็นคอลัมน์ boolean in_<facet> ใน CTE
+	var common []string
+	facetConds := map[string][]string{}
+	for i, cond := range q.conditions {
+		if facet := q.facets[i]; facet != "" {
+			facetConds[facet] = append(facetConds[facet], cond)
+		} else {
+			common = append(common, cond)
+		}
+	}
+	flags := ""
+	var filteredFacets []string
+	for _, facet := range []string{"category", "year", "language", "publisher", "price"} {
+		if conds := facetConds[facet]; len(conds) > 0 {
+			flags += ", (" + strings.Join(conds, " AND ") + ") AS in_" + facet
+			filteredFacets = append(filteredFacets, facet)
+		}
+	}
+	// matching คืนเงื่อนไขของทุก facet ยกเว้น except ("" คือครบทุก facet สำหรับ total)
+	matching := func(except string) string {
+		conds := []string{"TRUE"}
+		for _, facet := range filteredFacets {
+			if facet != except {
+				conds = append(conds, "in_"+facet)
+			}
+		}
+		return strings.Join(conds, " AND ")
+	}
+
+	where := ""
+	if len(common) > 0 {
+		where = " WHERE " + strings.Join(common, " AND ")
+	}
+
+	// ทุก facet มาจาก CTE เดียวกันใน query เดียว แถว total ใช้เก็บจำนวนทั้งหมดหลัง filter
+	// ช่วงราคานับจากราคาขาย ตรงกับ min_price/max_price และราคาที่แสดง
+	query := `
+        WITH filtered AS (
+            SELECT category, year, language, publisher, ` + bookSalePriceExpr + ` AS sale_price, rating` + flags + `
+            FROM ` + bookFrom + where + `
+        )
+        SELECT 'total', '', COUNT(*) FROM filtered
+        WHERE ` + matching("") + `
+        UNION ALL
+        SELECT 'category', category::text, COUNT(*) FROM filtered
+        WHERE category IS NOT NULL AND category <> '' AND ` + matching("category") + ` GROUP BY category
+        UNION ALL
+        SELECT 'year', year::text, COUNT(*) FROM filtered
+        WHERE year IS NOT NULL AND ` + matching("year") + ` GROUP BY year
+        UNION ALL
+        SELECT 'language', language::text, COUNT(*) FROM filtered
+        WHERE language IS NOT NULL AND language <> '' AND ` + matching("language") + ` GROUP BY language
+        UNION ALL
+        SELECT 'publisher', publisher::text, COUNT(*) FROM filtered
+        WHERE publisher IS NOT NULL AND publisher <> '' AND ` + matching("publisher") + ` GROUP BY publisher
+        UNION ALL
+        SELECT 'price', ` + bucketCase("sale_price", priceFacetBuckets) + `, COUNT(*) FROM filtered
+        WHERE sale_price IS NOT NULL AND ` + matching("price") + ` GROUP BY 2
+        UNION ALL
+        SELECT 'rating', ` + bucketCase("COALESCE(rating, 0)", ratingFacetBuckets) + `, COUNT(*) FROM filtered
+        WHERE ` + matching("rating") + ` GROUP BY 2
+    `
+
+	rows, err := db.Query(query, q.args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	resp := BookFacetsResponse{Facets: map[string][]FacetValue{}}
+	counts := map[string]map[string]int{}
+	for rows.Next() {
+		var facet string
+		var value sql.NullString
+		var count int
+		if err := rows.Scan(&facet, &value, &count); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if facet == "total" {
+			resp.Total = count
+			continue
+		}
+		if !value.Valid {
+			continue
+		}
+		if counts[facet] == nil {
+			counts[facet] = map[string]int{}
+		}
+		counts[facet][value.String] = count
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// facet แบบค่า: เรียงตามจำนวนมากไปน้อย ยกเว้น year เรียงปีล่าสุดก่อน
+	for _, facet := range []string{"category", "year", "language", "publisher"} {
+		values := []FacetValue{}
+		for value, count := range counts[facet] {
+			values = append(values, FacetValue{Value: value, Count: count})
+		}
+		sort.Slice(values, func(i, j int) bool {
+			if facet == "year" {
+				return values[i].Value > values[j].Value
+			}
+			if values[i].Count != values[j].Count {
+				return values[i].Count > values[j].Count
+			}
+			return values[i].Value < values[j].Value
+		})
+		resp.Facets[facet] = values
+	}
+
+	// facet แบบช่วง: คืนทุก bucket ตามลำดับที่กำหนด รวม bucket ที่เป็น 0
+	for facet, buckets := range map[string][]facetBucket{"price": priceFacetBuckets, "rating": ratingFacetBuckets} {
+		values := make([]FacetValue, len(buckets))
+		for i, bucket := range buckets {
+			values[i] = FacetValue{Value: bucket.label, Count: counts[facet][bucket.label]}
+		}
+		resp.Facets[facet] = values
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// @Summary     Get featured books
+// @Description Get books with high ratings (4.0+)
+// @Tags        Books
+// @Accept      json
+// @Produce     json
+// @Param       limit   query  int     false  "Number of books to return (default 10, max 100)"
+// @Param       cursor  query  string  false  "Opaque cursor from next_cursor of the previous page"
+// @Param       sort    query  string  false  "Comma separated sort keys (default -rating,-reviews_count)"
+// @Param       fields  query  string  false  "Comma separated fields to return"
+// @Success     200  {object}  BookListResponse
+// @Failure     400  {object}  ErrorResponse
+// @Failure     500  {object}  ErrorResponse
+// @Router      /books/featured [get]
+func getFeaturedBooks(c *gin.Context) {
+	q, err := parseBookListQuery(c, "-rating,-reviews_count", 10)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	q.where("rating >= ?", 4.0)
+
+	listBooks(c, q)
+}
+
+// @Summary     Get discounted books
+// @Description Get books whose sale price is lowered by an active promotion
+// @Tags        Books
+// @Accept      json
+// @Produce     json
+// @Param       limit   query  int     false  "Page size (default 20, max 100)"
+// @Param       cursor  query  string  false  "Opaque cursor from next_cursor of the previous page"
+// @Param       sort    query  string  false  "Comma separated sort keys (default -discount,-rating)"
+// @Param       fields  query  string  false  "Comma separated fields to return"
+// @Success     200  {object}  BookListResponse
+// @Failure     400  {object}  ErrorResponse
+// @Failure     500  {object}  ErrorResponse
+// @Router      /books/discounted [get]
+func getDiscountedBooks(c *gin.Context) {
+	q, err := parseBookListQuery(c, "-discount,-rating", 20)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	q.where(bookSalePriceExpr + " < price")
+
+	listBooks(c, q)
+}
+
+// maxImportBytes จำกัดขนาดไฟล์ที่ import ได้ในครั้งเดียว
+const maxImportBytes = 10 << 20
+
+// bookCSVColumns คือหัวคอลัมน์ของไฟล์ CSV ใช้ชื่อเดียวกับ json tag ของ Book
+// ไฟล์ที่ export ออกไปจึง import กลับเข้ามาได้ทันที
+// (id, sale_price, discount, rating, reviews_count, created_at, updated_at เป็นค่าของระบบและจะถูกข้าม)
+var bookCSVColumns = []string{
+	"id", "title", "author", "isbn", "year", "price",
+	"category", "sale_price", "discount", "cover_image",
+	"rating", "reviews_count", "is_new", "pages",
+	"language", "publisher", "description",
+	"created_at", "updated_at",
+}
+
+// bookCSVKinds บอกชนิดของคอลัมน์ CSV ที่ไม่ใช่ string เพื่อแปลงค่าก่อน decode เป็น Book
+var bookCSVKinds = map[string]string{
+	"id": "int", "year": "int", "discount": "int", "reviews_count": "int", "pages": "int",
+	"price": "float", "sale_price": "float", "original_price": "float", "rating": "float",
+	"is_new":     "bool",
+	"created_at": "time", "updated_at": "time",
+}
+
+// ImportRowError คือ error ของแถวหนึ่งในไฟล์ import (row เริ่มที่ 1 ไม่นับหัว CSV)
+type ImportRowError struct {
+	Row    int      `json:"row"`
+	ISBN   string   `json:"isbn,omitempty"`
+	Errors []string `json:"errors"`
+}
+
+// ImportResponse สรุปผลการ import
+type ImportResponse struct {
+	DryRun    bool             `json:"dry_run"`
+	Committed bool             `json:"committed"`
+	Total     int              `json:"total"`
+	Created   int              `json:"created"`
+	Updated   int              `json:"updated"`
+	Failed    int              `json:"failed"`
+	Errors    []ImportRowError `json:"errors"`
+}
+
+type importRow struct {
+	book   Book
+	errors []string
+}
+
+// bookDataFormat เลือกรูปแบบไฟล์จาก ?format= ก่อน แล้วค่อยดูจาก Content-Type
+func bookDataFormat(c *gin.Context) string {
+	if format := c.Query("format"); format != "" {
+		return format
+	}
+	switch c.ContentType() {
+	case "text/csv":
+		return "csv"
+	case "application/x-ndjson", "application/ndjson":
+		return "ndjson"
+	}
+	return "json"
+}
+
+// decodeImportObject decode object หนึ่งแถวเป็น Book โดยไม่ยอมรับ field ที่ Book ไม่มี
+func decodeImportObject(data []byte) (Book, error) {
+	var book Book
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&book)
+	return book, err
+}
+
+func parseImportJSON(body []byte) ([]importRow, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(body, &items); err != nil {
+		return nil, errors.New("JSON import must be an array of books")
+	}
+	rows := make([]importRow, len(items))
+	for i, item := range items {
+		book, err := decodeImportObject(item)
+		rows[i].book = book
+		if err != nil {
+			rows[i].errors = append(rows[i].errors, err.Error())
+		}
+	}
+	return rows, nil
+}
+
+func parseImportNDJSON(body []byte) ([]importRow, error) {
+	var rows []importRow
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), maxImportBytes)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var row importRow
+		book, err := decodeImportObject(line)
+		row.book = book
+		if err != nil {
+			row.errors = append(row.errors, err.Error())
+		}
+		rows = append(rows, row)
+	}
+	return rows, scanner.Err()
+}
+
+func parseImportCSV(body []byte) ([]importRow, error) {
+	reader := csv.NewReader(bytes.NewReader(body))
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("CSV import requires a header row")
+	}
+	// original_price อยู่ในไฟล์ที่ export ก่อน migrations/009 รับไว้แต่ไม่นำเข้า
+	known := map[string]bool{"original_price": true}
+	for _, column := range bookCSVColumns {
+		known[column] = true
+	}
+	for i, column := range header {
+		// ไฟล์ที่ save จาก Excel มักมี BOM นำหน้าคอลัมน์แรก
+		header[i] = strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))
+		if !known[header[i]] {
+			return nil, fmt.Errorf("unknown CSV column %q", header[i])
+		}
+	}
+
+	var rows []importRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var row importRow
+		if len(record) != len(header) {
+			row.errors = append(row.errors, fmt.Sprintf("expected %d columns, got %d", len(header), len(record)))
+			rows = append(rows, row)
+			continue
+		}
+		doc := map[string]interface{}{}
+		for i, column := range header {
+			value := strings.TrimSpace(record[i])
+			// ช่องว่างหมายถึงไม่ระบุ (NULL สำหรับ pages)
+			if value == "" {
+				continue
+			}
+			switch bookCSVKinds[column] {
+			case "int":
+				n, err := strconv.Atoi(value)
+				if err != nil {
+					row.errors = append(row.errors, fmt.Sprintf("%s must be an integer", column))
+					continue
+				}
+				doc[column] = n
+			case "float":
+				f, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					row.errors = append(row.errors, fmt.Sprintf("%s must be a number", column))
+					continue
+				}
+				doc[column] = f
+			case "bool":
+				b, err := strconv.ParseBool(value)
+				if err != nil {
+					row.errors = append(row.errors, fmt.Sprintf("%s must be true or false", column))
+					continue
+				}
+				doc[column] = b
+			case "time":
+				// created_at / updated_at เป็นค่าที่ระบบกำหนดเอง ไม่นำเข้า
+			default:
+				doc[column] = csvUnescape(value)
+			}
+		}
+
+		data, _ := json.Marshal(doc)
+		book, err := decodeImportObject(data)
+		row.book = book
+		if err != nil {
+			row.errors = append(row.errors, err.Error())
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// validateImportBook ตรวจค่าของหนังสือหนึ่งแถวก่อนเขียนลงฐานข้อมูล
+func validateImportBook(book Book) []string {
+	var errs []string
+	if strings.TrimSpace(book.Title) == "" {
+		errs = append(errs, "title is required")
+	}
+	if strings.TrimSpace(book.ISBN) == "" {
+		errs = append(errs, "isbn is required")
+	}
+	if book.Year < 0 || book.Year > time.Now().Year()+1 {
+		errs = append(errs, "year is out of range")
+	}
+	if book.Price < 0 {
+		errs = append(errs, "price must not be negative")
+	}
+	if book.Pages != nil && *book.Pages <= 0 {
+		errs = append(errs, "pages must be positive")
+	}
+	return errs
+}
+
+// @Summary     Import books
+// @Description Upsert books by ISBN from CSV, JSON array or NDJSON in a single transaction.
+// @Description If any row fails nothing is written. dry_run=true validates every row and reports what would change.
+// @Tags        Books
+// @Accept      json
+// @Produce     json
+// @Param       format   query  string  false  "csv, json or ndjson (default from Content-Type)"
+// @Param       dry_run  query  bool    false  "Validate only, never commit"
+// @Success     200  {object}  ImportResponse
+// @Failure     400  {object}  ErrorResponse
+// @Failure     413  {object}  ErrorResponse
+// @Failure     422  {object}  ImportResponse
+// @Failure     500  {object}  ErrorResponse
+// @Router      /books/import [post]
+func importBooks(c *gin.Context) {
+	dryRun := c.Query("dry_run") == "true"
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("import is limited to %d bytes", maxImportBytes)})
+		return
+	}
+
+	var rows []importRow
+	switch bookDataFormat(c) {
+	case "csv":
+		rows, err = parseImportCSV(body)
+	case "ndjson":
+		rows, err = parseImportNDJSON(body)
+	case "json":
+		rows, err = parseImportJSON(body)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv, json or ndjson"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	resp := ImportResponse{DryRun: dryRun, Total: len(rows), Errors: []ImportRowError{}}
+	seen := map[string]int{}
+	for i, row := range rows {
+		book := row.book
+		errs := row.errors
+		if len(errs) == 0 {
+			errs = validateImportBook(book)
+		}
+		if first, ok := seen[book.ISBN]; ok && book.ISBN != "" {
+			errs = append(errs, fmt.Sprintf("duplicate isbn (first seen at row %d)", first))
+		} else if book.ISBN != "" {
+			seen[book.ISBN] = i + 1
+		}
+
+		// ใช้ savepoint ต่อแถว เพื่อให้ error ของแถวหนึ่งไม่ทำให้ทั้ง transaction ใช้ต่อไม่ได้
+		// และรายงาน error ของทุกแถวได้ในครั้งเดียว
+		if len(errs) == 0 {
+			var inserted bool
+			_, err := tx.Exec("SAVEPOINT import_row")
+			if err == nil {
+				err = tx.QueryRow(`
+                    INSERT INTO books (
+                        title, author, isbn, year, price,
+                        category, cover_image,
+                        is_new, pages,
+                        language, publisher, description
+                    )
+                    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+                    ON CONFLICT (isbn) WHERE deleted_at IS NULL AND isbn <> '' DO UPDATE SET
+                        title = EXCLUDED.title, author = EXCLUDED.author, year = EXCLUDED.year,
+                        price = EXCLUDED.price, category = EXCLUDED.category,
+                        cover_image = EXCLUDED.cover_image, is_new = EXCLUDED.is_new,
+                        pages = EXCLUDED.pages, language = EXCLUDED.language,
+                        publisher = EXCLUDED.publisher, description = EXCLUDED.description
+                    RETURNING (xmax = 0)`,
+					book.Title, book.Author, book.ISBN, book.Year, book.Price,
+					book.Category, book.CoverImage,
+					book.IsNew, book.Pages,
+					book.Language, book.Publisher, book.Description,
+				).Scan(&inserted)
+			}
+			if err != nil {
+				if _, rbErr := tx.Exec("ROLLBACK TO SAVEPOINT import_row"); rbErr != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": rbErr.Error()})
+					return
+				}
+				errs = append(errs, err.Error())
+			} else if inserted {
+				resp.Created++
+			} else {
+				resp.Updated++
+			}
+		}
+
+		if len(errs) > 0 {
+			resp.Failed++
+			resp.Errors = append(resp.Errors, ImportRowError{Row: i + 1, ISBN: book.ISBN, Errors: errs})
+		}
+	}
+
+	if resp.Failed > 0 {
+		c.JSON(http.StatusUnprocessableEntity, resp)
+		return
+	}
+	if !dryRun {
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		resp.Committed = true
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// csvSafe กัน formula injection: ข้อความที่ขึ้นต้นด้วย = + - @ (หรือ tab / CR) จะถูก Excel
+// ตีความเป็นสูตร จึงเติม ' นำหน้า ตอน import csvUnescape จะตัด ' ออกให้ไฟล์ที่ export กลับเข้ามาได้เหมือนเดิม
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func csvUnescape(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune("=+-@\t\r", rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
+
+func bookCSVRecord(book Book) []string {
+	pages := ""
+	if book.Pages != nil {
+		pages = strconv.Itoa(*book.Pages)
+	}
+	return []string{
+		strconv.Itoa(book.ID), csvSafe(book.Title), csvSafe(book.Author), csvSafe(book.ISBN),
+		strconv.Itoa(book.Year), strconv.FormatFloat(book.Price, 'f', -1, 64),
+		csvSafe(book.Category), strconv.FormatFloat(book.SalePrice, 'f', -1, 64), strconv.Itoa(book.Discount), csvSafe(book.CoverImage),
+		strconv.FormatFloat(book.Rating, 'f', -1, 64), strconv.Itoa(book.ReviewsCount),
+		strconv.FormatBool(book.IsNew), pages,
+		csvSafe(book.Language), csvSafe(book.Publisher), csvSafe(book.Description),
+		book.CreatedAt.Format(time.RFC3339), book.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// @Summary     Export books
+// @Description Stream the catalog as CSV, JSON array or NDJSON. Accepts the same filters as /books.
+// @Tags        Books
+// @Produce     json
+// @Param       format     query  string  false  "csv, json or ndjson (default json)"
+// @Param       year       query  int     false  "Filter by year"
+// @Param       category   query  string  false  "Filter by category"
+// @Param       language   query  string  false  "Filter by language"
+// @Param       publisher  query  string  false  "Filter by publisher"
+// @Param       author_id  query  int     false  "Filter by author ID"
+// @Param       publisher_id  query  int  false  "Filter by publisher ID"
+// @Param       category_id   query  int  false  "Filter by category ID"
+// @Param       in_stock      query  bool false  "Only books that are (true) or are not (false) available"
+// @Param       min_price  query  number  false  "Minimum sale price"
+// @Param       max_price  query  number  false  "Maximum sale price"
+// @Param       min_year   query  int     false  "Minimum year"
+// @Param       max_year   query  int     false  "Maximum year"
+// @Success     200  {array}   Book
+// @Failure     400  {object}  ErrorResponse
+// @Failure     500  {object}  ErrorResponse
+// @Router      /books/export [get]
+func exportBooks(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	contentTypes := map[string]string{
+		"csv":    "text/csv; charset=utf-8",
+		"json":   "application/json; charset=utf-8",
+		"ndjson": "application/x-ndjson",
+	}
+	contentType, ok := contentTypes[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv, json or ndjson"})
+		return
+	}
+
+	q := &bookListQuery{}
+	if err := parseBookFilters(c, q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rows, err := db.Query("SELECT "+bookColumns+" FROM "+bookFrom+" WHERE "+strings.Join(q.conditions, " AND ")+" ORDER BY id", q.args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="books.%s"`, format))
+	c.Status(http.StatusOK)
+
+	// เขียนทีละแถวแล้ว flush เป็นช่วงๆ ไม่ต้องโหลดทั้ง catalog เข้า memory
+	w := c.Writer
+	csvWriter := csv.NewWriter(w)
+	enc := json.NewEncoder(w)
+	switch format {
+	case "csv":
+		csvWriter.Write(bookCSVColumns)
+	case "json":
+		w.WriteString("[")
+	}
+
+	count := 0
+	for rows.Next() {
+		book, err := scanBook(rows)
+		if err != nil {
+			// header ถูกส่งไปแล้ว ทำได้แค่หยุดเขียนและบันทึก log
+			log.Printf("export books: %v", err)
+			return
+		}
+
+		switch format {
+		case "csv":
+			csvWriter.Write(bookCSVRecord(book))
+		case "json":
+			if count > 0 {
+				w.WriteString(",")
+			}
+			enc.Encode(book)
+		case "ndjson":
+			enc.Encode(book)
+		}
+
+		count++
+		if count%500 == 0 {
+			csvWriter.Flush()
+			w.Flush()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("export books: %v", err)
+		return
+	}
+
+	switch format {
+	case "csv":
+		csvWriter.Flush()
+	case "json":
+		w.WriteString("]")
+	}
+	w.Flush()
+}
+
+// errBlobNotFound คืนจาก BlobStore.Get เมื่อไม่มี object ตาม key
+var errBlobNotFound = errors.New("blob not found")
+
+// BlobStore เก็บไฟล์ binary (ภาพปก) โดยอ้างอิงด้วย key แบบ path เช่น covers/12/ab34/w320.jpg
+// เปลี่ยนไปใช้ object storage อื่นได้โดย implement interface นี้
+type BlobStore interface {
+	Put(key string, data []byte, contentType string) error
+	Get(key string) (io.ReadCloser, string, error)
+	// DeletePrefix ลบทุก object ที่ key ขึ้นต้นด้วย prefix
+	DeletePrefix(prefix string) error
+	// List คืนชื่อระดับถัดไปใต้ prefix เช่น List("covers") คืน id ของหนังสือที่มีภาพปก
+	List(prefix string) ([]string, error)
+}
+
+// localBlobStore เก็บไฟล์ไว้ใน directory บนเครื่อง content type อนุมานจากนามสกุลไฟล์
+type localBlobStore struct {
+	dir string
+}
+
+// path แปลง key เป็น path จริง โดย Clean จาก root เพื่อกัน key แบบ ../ หลุดออกนอก dir
+func (s localBlobStore) path(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(path.Clean("/"+key)))
+}
+
+func (s localBlobStore) Put(key string, data []byte, contentType string) error {
+	p := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	// เขียนไฟล์ชั่วคราวแล้ว rename เพื่อไม่ให้ผู้อ่านเห็นไฟล์ที่เขียนไม่ครบ
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+func (s localBlobStore) Get(key string) (io.ReadCloser, string, error) {
+	f, err := os.Open(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, "", errBlobNotFound
+	} else if err != nil {
+		return nil, "", err
+	}
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return f, contentType, nil
+}
+
+func (s localBlobStore) DeletePrefix(prefix string) error {
+	return os.RemoveAll(s.path(prefix))
+}
+
+func (s localBlobStore) List(prefix string) ([]string, error) {
+	entries, err := os.ReadDir(s.path(prefix))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names, nil
+}
+
+var (
+	coverStore BlobStore = localBlobStore{dir: getEnv("COVER_STORAGE_DIR", "./uploads")}
+
+	// coverBaseURL ใช้สร้าง URL ของภาพใน cover_image ค่าว่างจะได้ path แบบ relative
+	coverBaseURL = strings.TrimSuffix(getEnv("COVER_BASE_URL", ""), "/")
+)
+
+const (
+	maxCoverBytes = 5 << 20
+	// จำกัดจำนวน pixel ก่อน decode เพื่อกันไฟล์เล็กที่ขยายเป็นภาพใหญ่มาก (decompression bomb)
+	maxCoverPixels = 40_000_000
+)
+
+// coverThumbnailWidths คือความกว้าง (pixel) ของ thumbnail ที่สร้างทุกครั้งที่อัปโหลด
+var coverThumbnailWidths = []int{160, 320, 640}
+
+// coverExtensions คือชนิดไฟล์ที่รับ ตรวจจากเนื้อไฟล์จริงไม่ใช่จาก header ที่ client ส่งมา
+var coverExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// CoverResponse คือ response ของการอัปโหลดภาพปก
+type CoverResponse struct {
+	CoverImage string            `json:"cover_image"`
+	Thumbnails map[string]string `json:"thumbnails"`
+}
+
+// coverURL แปลง key (covers/...) เป็น URL ของ getCover
+func coverURL(key string) string {
+	return coverBaseURL + "/api/v1/" + key
+}
+
+// resizeImage ย่อภาพให้กว้าง width pixel (คงสัดส่วน) ด้วยการเฉลี่ย pixel ในแต่ละช่อง (box filter)
+// พื้นโปร่งใสจะถูกวางบนพื้นขาวเพราะ thumbnail เก็บเป็น JPEG
+func resizeImage(src image.Image, width int) *image.RGBA {
+	b := src.Bounds()
+	if width > b.Dx() {
+		width = b.Dx()
+	}
+	height := b.Dy() * width / b.Dx()
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := b.Min.Y + y*b.Dy()/height
+		y1 := b.Min.Y + (y+1)*b.Dy()/height
+		for x := 0; x < width; x++ {
+			x0 := b.Min.X + x*b.Dx()/width
+			x1 := b.Min.X + (x+1)*b.Dx()/width
+
+			var r, g, bl, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					// ค่าเป็น premultiplied alpha บวกส่วนที่โปร่งใสด้วยสีขาว
+					r += uint64(cr + 0xffff - ca)
+					g += uint64(cg + 0xffff - ca)
+					bl += uint64(cb + 0xffff - ca)
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r / n >> 8)
+			dst.Pix[i+1] = uint8(g / n >> 8)
+			dst.Pix[i+2] = uint8(bl / n >> 8)
+			dst.Pix[i+3] = 0xff
+		}
+	}
+	return dst
+}
+
+// @Summary     Upload book cover
+// @Description Upload a JPEG, PNG or GIF cover (max 5 MB). Thumbnails are generated and cover_image is updated.
+// @Tags        Books
+// @Accept      multipart/form-data
+// @Produce     json
+// @Param       id        path      int     true   "Book ID"
+// @Param       If-Match  header    string  false  "ETag of the version being updated"
+// @Param       cover     formData  file    true   "Cover image"
+// @Success     200  {object}  CoverResponse
+// @Failure     400  {object}  ErrorResponse
+// @Failure     404  {object}  ErrorResponse
+// @Failure     412  {object}  ErrorResponse
+// @Failure     413  {object}  ErrorResponse
+// @Failure     415  {object}  ErrorResponse
+// @Failure     500  {object}  ErrorResponse
+// @Router      /books/{id}/cover [post]
+func uploadBookCover(c *gin.Context) {
+	id := c.Param("id")
+	// id ถูกใช้เป็นส่วนหนึ่งของ key จึงต้องเป็นตัวเลขเท่านั้น
+	if _, err := strconv.Atoi(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid book id"})
+		return
+	}
+
+	if !checkIfMatchPresent(c) {
+		return
+	}
+
+	// เผื่อขนาดของ multipart header นอกเหนือจากตัวไฟล์
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxCoverBytes+64<<10)
+	file, header, err := c.Request.FormFile("cover")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "cover image must be at most 5 MB"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cover file is required"})
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxCoverBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "cover image must be at most 5 MB"})
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(file, maxCoverBytes+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(data) > maxCoverBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "cover image must be at most 5 MB"})
+		return
+	}
+
+	contentType := http.DetectContentType(data)
+	ext, ok := coverExtensions[contentType]
+	if !ok {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "cover must be a JPEG, PNG or GIF image"})
+		return
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot read image: " + err.Error()})
+		return
+	}
+	if config.Width*config.Height > maxCoverPixels {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "image dimensions are too large"})
+		return
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot read image: " + err.Error()})
+		return
+	}
+	// ภาพขนาด 0 pixel (เช่น GIF 0x0) decode ผ่านแต่ย่อเป็น thumbnail ไม่ได้
+	if b := img.Bounds(); b.Dx() == 0 || b.Dy() == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "image has no pixels"})
+		return
+	}
+
+	// key อิงจาก hash ของเนื้อไฟล์ต่อด้วยค่าสุ่มของการอัปโหลดครั้งนี้ ภาพใหม่จึงได้ URL ใหม่เสมอและ cache แบบ immutable ได้
+	// ค่าสุ่มทำให้การอัปโหลดไฟล์เดิมซ้ำไม่ได้ prefix เดียวกับภาพปกปัจจุบัน
+	// การลบ prefix ตอนล้มเหลวด้านล่างจึงไม่ไปลบไฟล์ที่ cover_image ยังชี้อยู่
+	sum := sha256.Sum256(data)
+	nonce := make([]byte, 4)
+	if _, err := rand.Read(nonce); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	prefix := fmt.Sprintf("covers/%s/%s-%s", id, hex.EncodeToString(sum[:8]), hex.EncodeToString(nonce))
+
+	if err := coverStore.Put(prefix+"/original"+ext, data, contentType); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := CoverResponse{CoverImage: coverURL(prefix + "/original" + ext), Thumbnails: map[string]string{}}
+
+	// ย่อจากภาพใหญ่ไปเล็ก โดยใช้ thumbnail ก่อนหน้าเป็นต้นฉบับเพื่อลดงาน
+	widths := append([]int{}, coverThumbnailWidths...)
+	sort.Sort(sort.Reverse(sort.IntSlice(widths)))
+	source := img
+	for _, width := range widths {
+		thumb := resizeImage(source, width)
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 85}); err != nil {
+			coverStore.DeletePrefix(prefix)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		key := fmt.Sprintf("%s/w%d.jpg", prefix, width)
+		if err := coverStore.Put(key, buf.Bytes(), "image/jpeg"); err != nil {
+			coverStore.DeletePrefix(prefix)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		resp.Thumbnails[strconv.Itoa(width)] = coverURL(key)
+		source = thumb
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		coverStore.DeletePrefix(prefix)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	current, err := lockBook(tx, id)
+	if err == sql.ErrNoRows {
+		coverStore.DeletePrefix(prefix)
+		c.JSON(http.StatusNotFound, gin.H{"error": "book not found"})
+		return
+	} else if err != nil {
+		coverStore.DeletePrefix(prefix)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkIfMatch(c, current) {
+		coverStore.DeletePrefix(prefix)
+		return
+	}
+
+	updated, err := scanBook(tx.QueryRow(returningBook(`
+        UPDATE books SET cover_image = $1
+        WHERE id = $2`), resp.CoverImage, id))
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		coverStore.DeletePrefix(prefix)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// ลบภาพปกชุดเดิมที่อัปโหลดผ่าน endpoint นี้ (ถ้าเป็น URL ภายนอกจะไม่แตะ)
+	oldPrefix := coverURL(fmt.Sprintf("covers/%s/", id))
+	if strings.HasPrefix(current.CoverImage, oldPrefix) && current.CoverImage != resp.CoverImage {
+		oldKey := strings.TrimPrefix(current.CoverImage, coverBaseURL+"/api/v1/")
+		if err := coverStore.DeletePrefix(path.Dir(oldKey)); err != nil {
+			log.Printf("delete old cover %s: %v", oldKey, err)
+		}
+	}
+
+	c.Header("ETag", bookETag(updated))
+	c.JSON(http.StatusOK, resp)
+}
+
+// @Summary     Get cover image
+// @Description Serve an uploaded cover or thumbnail. URLs are content-addressed so responses are cached as immutable.
+// @Tags        Books
+// @Produce     image/jpeg
+// @Param       key  path  string  true  "Path after /covers/ in cover_image"
+// @Success     200  {file}  binary
+// @Failure     404  {object}  ErrorResponse
+// @Router      /covers/{key} [get]
+func getCover(c *gin.Context) {
+	key := "covers" + c.Param("key")
+
+	etag := `"` + key + `"`
+	if header := c.GetHeader("If-None-Match"); header != "" && etagMatches(header, etag) {
+		c.Header("ETag", etag)
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	reader, contentType, err := coverStore.Get(key)
+	if errors.Is(err, errBlobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "cover not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer reader.Close()
+
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.Header("ETag", etag)
+	c.DataFromReader(http.StatusOK, -1, contentType, reader, nil)
+}
+
+// coverCleanupInterval คือรอบการลบภาพปกของหนังสือที่ถูกลบถาวรไปแล้ว
+var coverCleanupInterval = getEnvDuration("COVER_CLEANUP_INTERVAL", time.Hour)
+
+// cleanupOrphanCovers ลบภาพปกใต้ covers/<id> ของหนังสือที่ไม่มีในตาราง books แล้ว
+// หนังสือถูกลบถาวรโดยงาน purge ของ week13-lab6 ซึ่งไม่เห็นที่เก็บไฟล์ของ service นี้
+func cleanupOrphanCovers() (int, error) {
+	names, err := coverStore.List("covers")
+	if err != nil {
+		return 0, err
+	}
+	ids := []int64{}
+	for _, name := range names {
+		if id, err := strconv.ParseInt(name, 10, 32); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	rows, err := db.Query(`
+        SELECT id FROM unnest($1::int[]) AS c(id)
+        WHERE NOT EXISTS (SELECT 1 FROM books WHERE books.id = c.id)`, pq.Array(ids))
+	if err != nil {
+		return 0, err
+	}
+	orphans := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		orphans = append(orphans, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, id := range orphans {
+		if err := coverStore.DeletePrefix(fmt.Sprintf("covers/%d", id)); err != nil {
+			return 0, err
+		}
+	}
+	return len(orphans), nil
+}
+
+// cleanupCoversPeriodically ลบภาพปกที่ไม่มีหนังสือแล้วทุก coverCleanupInterval
+func cleanupCoversPeriodically() {
+	ticker := time.NewTicker(coverCleanupInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if n, err := cleanupOrphanCovers(); err != nil {
+			log.Printf("cover cleanup failed: %v", err)
+		} else if n > 0 {
+			log.Printf("removed covers of %d purged book(s)", n)
+		}
+	}
+}
+
+// @title           Simple API Example
+// @version         1.0
+// @description     This is a simple example of using Gin with Swagger.
+// @host            localhost:8080
+// @BasePath        /api/v1
+func main() {
+	initDB()
+	defer db.Close()
+
+	go cleanupCoversPeriodically()
 
 	r := gin.Default()
 	r.GET("/health", getHealth)
 
 	config := cors.DefaultConfig()
 	config.AllowAllOrigins = true
+	config.AddAllowHeaders("If-Match", "If-None-Match")
+	config.AddExposeHeaders("ETag")
 	r.Use(cors.New(config))
 
 	r.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
@@ -617,13 +3603,36 @@ func main() {
 		api.GET("/books", getAllBooks)
 		api.GET("/books/new", getNewBooks)
 		api.GET("/books/search", searchBooks)
+		api.GET("/books/facets", getBookFacets)
+		api.POST("/books/import", importBooks)
+		api.GET("/books/export", exportBooks)
 		api.GET("/books/featured", getFeaturedBooks)
 		api.GET("/books/discounted", getDiscountedBooks)
 		api.GET("/books/:id", getBook)
 		api.POST("/books", createBook)
 		api.PUT("/books/:id", updateBook)
+		api.PATCH("/books/:id", patchBook)
+		api.POST("/books/:id/cover", uploadBookCover)
 		api.DELETE("/books/:id", deleteBook)
+		api.GET("/authors", getAuthors)
+		api.POST("/authors", createAuthor)
+		api.GET("/authors/:id", getAuthor)
+		api.PUT("/authors/:id", updateAuthor)
+		api.DELETE("/authors/:id", deleteAuthor)
+		api.GET("/authors/:id/books", getAuthorBooks)
+		api.GET("/publishers", getPublishers)
+		api.POST("/publishers", createPublisher)
+		api.GET("/publishers/:id", getPublisher)
+		api.PUT("/publishers/:id", updatePublisher)
+		api.DELETE("/publishers/:id", deletePublisher)
+		api.GET("/publishers/:id/books", getPublisherBooks)
 		api.GET("/categories", getCategories)
+		api.POST("/categories", createCategory)
+		api.GET("/categories/:slug", getCategory)
+		api.PUT("/categories/:slug", updateCategory)
+		api.DELETE("/categories/:slug", deleteCategory)
+		api.GET("/categories/:slug/books", getCategoryBooks)
+		api.GET("/covers/*key", getCover)
 	}
 
 	r.Run(":8080")
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_books_search_vector;
+DROP TRIGGER IF EXISTS books_search_vector_trigger ON books;
+DROP FUNCTION IF EXISTS books_search_vector_update();
+ALTER TABLE books DROP COLUMN IF EXISTS search_vector;
+DROP FUNCTION IF EXISTS books_search_config();
@@ -0,0 +1,50 @@
+-- Full-text search สำหรับ /books/search
+-- ภาษาที่ใช้ stemming อ่านจาก setting app.search_language (ค่าเริ่มต้น english)
+-- ตั้งได้ด้วย postgres -c app.search_language=simple หรือ ALTER DATABASE ... SET app.search_language = '...'
+-- ถ้าเปลี่ยนภาษาหลังมีข้อมูลแล้ว ให้สร้าง vector ใหม่แบบเดียวกับการเติม vector ท้ายไฟล์นี้
+-- (ปิด update_books_modtime ระหว่าง UPDATE books SET search_vector = NULL; เพื่อไม่ให้ updated_at เปลี่ยน)
+CREATE OR REPLACE FUNCTION books_search_config()
+RETURNS regconfig AS $$
+    SELECT COALESCE(NULLIF(current_setting('app.search_language', true), ''), 'english')::regconfig;
+$$ LANGUAGE sql STABLE;
+
+ALTER TABLE books ADD COLUMN IF NOT EXISTS search_vector tsvector;
+
+-- น้ำหนัก: title (A) > author (B) > description (C)
+-- คำนวณใหม่เมื่อ title, author หรือ description เปลี่ยน หรือ search_vector เป็น NULL (ตอนสร้าง vector ใหม่)
+-- PUT ส่งทุกคอลัมน์มาเสมอ จึงต้องเทียบค่าเดิมด้วย ไม่ใช่ดูแค่รายชื่อคอลัมน์ใน UPDATE OF
+CREATE OR REPLACE FUNCTION books_search_vector_update()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE'
+       AND NEW.search_vector IS NOT NULL
+       AND NEW.search_vector IS NOT DISTINCT FROM OLD.search_vector
+       AND NEW.title IS NOT DISTINCT FROM OLD.title
+       AND NEW.author IS NOT DISTINCT FROM OLD.author
+       AND NEW.description IS NOT DISTINCT FROM OLD.description THEN
+        RETURN NEW;
+    END IF;
+    NEW.search_vector :=
+        setweight(to_tsvector(books_search_config(), COALESCE(NEW.title, '')), 'A') ||
+        setweight(to_tsvector(books_search_config(), COALESCE(NEW.author, '')), 'B') ||
+        setweight(to_tsvector(books_search_config(), COALESCE(NEW.description, '')), 'C');
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER books_search_vector_trigger
+BEFORE INSERT OR UPDATE OF title, author, description, search_vector ON books
+FOR EACH ROW
+EXECUTE FUNCTION books_search_vector_update();
+
+-- เติม vector ให้แถวที่มีอยู่แล้ว (trigger ด้านบนจะคำนวณให้)
+-- ปิด trigger ของ updated_at (bookstoredatabase/docker/init.sql) ไว้ เพราะเนื้อหาของหนังสือไม่ได้เปลี่ยน
+-- ถ้าไม่ปิด ทุกเล่มจะดูเหมือนเพิ่งถูกแก้ ETag และการ sort ตาม updated_at จะเปลี่ยนทั้งหมด
+-- ทำใน transaction เดียวเพื่อไม่ให้ trigger ค้างสถานะปิดถ้า UPDATE ล้ม
+BEGIN;
+ALTER TABLE books DISABLE TRIGGER update_books_modtime;
+UPDATE books SET search_vector = NULL;
+ALTER TABLE books ENABLE TRIGGER update_books_modtime;
+COMMIT;
+
+CREATE INDEX IF NOT EXISTS idx_books_search_vector ON books USING GIN (search_vector);
@@ -0,0 +1,5 @@
+-- Soft delete: DELETE /books/:id ตั้ง deleted_at แทนการลบแถว
+-- ทุก query ของ listing/search/facets กรอง deleted_at IS NULL
+ALTER TABLE books ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_books_deleted_at ON books(deleted_at) WHERE deleted_at IS NOT NULL;
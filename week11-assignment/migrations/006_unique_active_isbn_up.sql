@@ -0,0 +1,5 @@
+-- ISBN ต้องไม่ซ้ำในหนังสือที่ยังไม่ถูกลบ ใช้เป็น key ของการ upsert ใน POST /books/import
+-- (ถ้ามี ISBN ซ้ำอยู่แล้วต้องแก้ข้อมูลก่อนรัน migration นี้)
+CREATE UNIQUE INDEX IF NOT EXISTS idx_books_isbn_active
+ON books(isbn)
+WHERE deleted_at IS NULL AND isbn <> '';
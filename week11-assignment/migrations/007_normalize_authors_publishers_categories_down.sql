@@ -0,0 +1,20 @@
+DROP TRIGGER IF EXISTS books_link_authors_trigger ON books;
+DROP TRIGGER IF EXISTS books_link_publisher_category_trigger ON books;
+DROP FUNCTION IF EXISTS books_link_authors();
+DROP FUNCTION IF EXISTS books_link_publisher_category();
+DROP FUNCTION IF EXISTS sync_book_authors(INTEGER, TEXT);
+DROP FUNCTION IF EXISTS ensure_category(TEXT);
+DROP FUNCTION IF EXISTS ensure_publisher(TEXT);
+DROP FUNCTION IF EXISTS ensure_author(TEXT);
+DROP FUNCTION IF EXISTS category_slug(TEXT);
+DROP FUNCTION IF EXISTS split_author_names(TEXT);
+
+DROP INDEX IF EXISTS idx_books_category_id;
+DROP INDEX IF EXISTS idx_books_publisher_id;
+ALTER TABLE books DROP COLUMN IF EXISTS category_id;
+ALTER TABLE books DROP COLUMN IF EXISTS publisher_id;
+
+DROP TABLE IF EXISTS book_authors;
+DROP TABLE IF EXISTS categories;
+DROP TABLE IF EXISTS publishers;
+DROP TABLE IF EXISTS authors;
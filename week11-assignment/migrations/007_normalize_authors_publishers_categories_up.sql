@@ -0,0 +1,166 @@
+-- แยก author / publisher / category ออกเป็นตารางของตัวเอง
+-- คอลัมน์ข้อความเดิมใน books ยังอยู่ (ใช้แสดงผล, full-text search, filter และ service อื่นที่เขียน books)
+-- trigger ด้านล่างจะผูกข้อความเหล่านั้นเข้ากับ entity ให้อัตโนมัติทุกครั้งที่ INSERT/UPDATE
+
+CREATE TABLE IF NOT EXISTS authors (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    bio TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_authors_name ON authors (lower(name));
+
+CREATE TABLE IF NOT EXISTS publishers (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_publishers_name ON publishers (lower(name));
+
+CREATE TABLE IF NOT EXISTS categories (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    slug VARCHAR(120) NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_name ON categories (lower(name));
+
+CREATE TRIGGER update_authors_modtime BEFORE UPDATE ON authors
+FOR EACH ROW EXECUTE FUNCTION update_modified_column();
+CREATE TRIGGER update_publishers_modtime BEFORE UPDATE ON publishers
+FOR EACH ROW EXECUTE FUNCTION update_modified_column();
+CREATE TRIGGER update_categories_modtime BEFORE UPDATE ON categories
+FOR EACH ROW EXECUTE FUNCTION update_modified_column();
+
+-- หนังสือหนึ่งเล่มมีผู้แต่งได้หลายคน position คือลำดับตามที่เขียนใน books.author
+-- ลบ author ที่ยังมีหนังสืออยู่ไม่ได้ (รวมเล่มที่อยู่ในถังขยะ)
+CREATE TABLE IF NOT EXISTS book_authors (
+    book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    author_id INTEGER NOT NULL REFERENCES authors(id) ON DELETE RESTRICT,
+    position SMALLINT NOT NULL,
+    PRIMARY KEY (book_id, author_id)
+);
+CREATE INDEX IF NOT EXISTS idx_book_authors_author ON book_authors(author_id);
+
+ALTER TABLE books ADD COLUMN IF NOT EXISTS publisher_id INTEGER REFERENCES publishers(id) ON DELETE RESTRICT;
+ALTER TABLE books ADD COLUMN IF NOT EXISTS category_id INTEGER REFERENCES categories(id) ON DELETE RESTRICT;
+CREATE INDEX IF NOT EXISTS idx_books_publisher_id ON books(publisher_id);
+CREATE INDEX IF NOT EXISTS idx_books_category_id ON books(category_id);
+
+-- แยกชื่อผู้แต่งจากข้อความ เช่น 'Nuttachot Promrit and Sajjaporn Waijanya'
+-- ตัวคั่นที่รองรับ: , ; & and และ (ไม่สนตัวพิมพ์เล็กใหญ่) ชื่อที่ซ้ำกันจะเหลือตัวแรก
+CREATE OR REPLACE FUNCTION split_author_names(names TEXT)
+RETURNS TABLE (name TEXT, position INTEGER) AS $$
+    SELECT part, (row_number() OVER (ORDER BY ord))::INTEGER
+    FROM (
+        SELECT DISTINCT ON (lower(btrim(part))) btrim(part) AS part, ord
+        FROM regexp_split_to_table(COALESCE(names, ''), '\s*(?:[,;&]|\s+and\s+|\s+และ\s+)\s*', 'i')
+             WITH ORDINALITY AS t(part, ord)
+        WHERE btrim(part) <> ''
+        ORDER BY lower(btrim(part)), ord
+    ) s
+    ORDER BY ord;
+$$ LANGUAGE sql IMMUTABLE;
+
+-- slug ของหมวดหมู่: ตัวพิมพ์เล็ก เว้นวรรคและเครื่องหมายวรรคตอนกลายเป็น -
+CREATE OR REPLACE FUNCTION category_slug(name TEXT)
+RETURNS TEXT AS $$
+    SELECT COALESCE(NULLIF(btrim(regexp_replace(lower(btrim(name)), '[[:space:][:punct:]]+', '-', 'g'), '-'), ''), 'category');
+$$ LANGUAGE sql IMMUTABLE;
+
+-- ensure_* คืน id ของ entity ที่ชื่อตรงกัน (ไม่สนตัวพิมพ์) หรือสร้างใหม่ถ้ายังไม่มี
+CREATE OR REPLACE FUNCTION ensure_author(author_name TEXT)
+RETURNS INTEGER AS $$
+DECLARE
+    author_id INTEGER;
+BEGIN
+    INSERT INTO authors (name) VALUES (author_name) ON CONFLICT ((lower(name))) DO NOTHING;
+    SELECT id INTO author_id FROM authors WHERE lower(name) = lower(author_name);
+    RETURN author_id;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION ensure_publisher(publisher_name TEXT)
+RETURNS INTEGER AS $$
+DECLARE
+    publisher_id INTEGER;
+BEGIN
+    INSERT INTO publishers (name) VALUES (publisher_name) ON CONFLICT ((lower(name))) DO NOTHING;
+    SELECT id INTO publisher_id FROM publishers WHERE lower(name) = lower(publisher_name);
+    RETURN publisher_id;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION ensure_category(category_name TEXT)
+RETURNS INTEGER AS $$
+DECLARE
+    category_id INTEGER;
+    new_slug TEXT;
+BEGIN
+    SELECT id INTO category_id FROM categories WHERE lower(name) = lower(category_name);
+    IF FOUND THEN
+        RETURN category_id;
+    END IF;
+
+    -- ชื่อต่างกันแต่ได้ slug เดียวกัน (เช่น 'Sci-Fi' กับ 'Sci Fi') ให้ต่อท้ายด้วย hash ของชื่อ
+    new_slug := category_slug(category_name);
+    IF EXISTS (SELECT 1 FROM categories WHERE slug = new_slug) THEN
+        new_slug := new_slug || '-' || substr(md5(lower(category_name)), 1, 6);
+    END IF;
+
+    INSERT INTO categories (name, slug) VALUES (category_name, new_slug) ON CONFLICT ((lower(name))) DO NOTHING;
+    SELECT id INTO category_id FROM categories WHERE lower(name) = lower(category_name);
+    RETURN category_id;
+END;
+$$ LANGUAGE plpgsql;
+
+-- สร้าง book_authors ของหนังสือใหม่ตามข้อความ author
+CREATE OR REPLACE FUNCTION sync_book_authors(target_book_id INTEGER, names TEXT)
+RETURNS VOID AS $$
+BEGIN
+    DELETE FROM book_authors WHERE book_id = target_book_id;
+    INSERT INTO book_authors (book_id, author_id, position)
+    SELECT target_book_id, ensure_author(s.name), s.position
+    FROM split_author_names(names) s
+    ON CONFLICT DO NOTHING;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION books_link_publisher_category()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.publisher_id := CASE WHEN btrim(COALESCE(NEW.publisher, '')) = '' THEN NULL
+                             ELSE ensure_publisher(btrim(NEW.publisher)) END;
+    NEW.category_id := CASE WHEN btrim(COALESCE(NEW.category, '')) = '' THEN NULL
+                            ELSE ensure_category(btrim(NEW.category)) END;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER books_link_publisher_category_trigger
+BEFORE INSERT OR UPDATE OF publisher, category ON books
+FOR EACH ROW
+EXECUTE FUNCTION books_link_publisher_category();
+
+CREATE OR REPLACE FUNCTION books_link_authors()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM sync_book_authors(NEW.id, NEW.author);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER books_link_authors_trigger
+AFTER INSERT OR UPDATE OF author ON books
+FOR EACH ROW
+EXECUTE FUNCTION books_link_authors();
+
+-- แยกข้อความของหนังสือที่มีอยู่แล้ว
+UPDATE books SET
+    publisher_id = CASE WHEN btrim(COALESCE(publisher, '')) = '' THEN NULL ELSE ensure_publisher(btrim(publisher)) END,
+    category_id = CASE WHEN btrim(COALESCE(category, '')) = '' THEN NULL ELSE ensure_category(btrim(category)) END;
+SELECT sync_book_authors(id, author) FROM books;
@@ -0,0 +1,10 @@
+-- จำนวนสินค้าของหนังสือแต่ละเล่ม แสดงเป็น stock_available ใน response ของ books
+-- การปรับยอดและการจอง (stock_reservations) ทำผ่าน service ของ week13-lab6 ซึ่งมีระบบสิทธิ์และ audit log
+-- หนังสือที่ยังไม่มีแถวในตารางนี้ถือว่า stock เป็น 0
+CREATE TABLE IF NOT EXISTS book_inventory (
+    book_id INTEGER PRIMARY KEY REFERENCES books(id) ON DELETE CASCADE,
+    on_hand INTEGER NOT NULL DEFAULT 0 CHECK (on_hand >= 0),
+    reserved INTEGER NOT NULL DEFAULT 0 CHECK (reserved >= 0),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (reserved <= on_hand)
+);
@@ -0,0 +1,17 @@
+ALTER TABLE books ADD COLUMN IF NOT EXISTS original_price DECIMAL(10,2);
+ALTER TABLE books ADD COLUMN IF NOT EXISTS discount INTEGER DEFAULT 0;
+
+-- เก็บราคาขายปัจจุบันกลับเป็น price และราคาปกติเป็น original_price
+UPDATE books SET
+    original_price = price,
+    discount = book_discount(id, category_id, price),
+    price = book_sale_price(id, category_id, price)
+WHERE book_sale_price(id, category_id, price) < price;
+
+DROP FUNCTION IF EXISTS book_discount(INTEGER, INTEGER, NUMERIC);
+DROP FUNCTION IF EXISTS book_sale_price(INTEGER, INTEGER, NUMERIC);
+DROP FUNCTION IF EXISTS promotion_unit_price(NUMERIC, TEXT, NUMERIC);
+DROP FUNCTION IF EXISTS book_promotions(INTEGER, INTEGER);
+
+DROP TABLE IF EXISTS promotion_targets;
+DROP TABLE IF EXISTS promotions;
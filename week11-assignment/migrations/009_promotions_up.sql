@@ -0,0 +1,117 @@
+-- โปรโมชันแทนคอลัมน์ discount / original_price ที่เคยกรอกเองทีละเล่ม
+-- หลัง migration นี้ books.price คือราคาปกติ ราคาขายคำนวณจากโปรโมชันที่กำลังใช้งานอยู่ (book_sale_price)
+-- โปรโมชันที่ requires_coupon ใช้ได้เฉพาะตอนกรอกคูปองที่ checkout (ตาราง coupons อยู่ใน week13-lab6)
+CREATE TABLE IF NOT EXISTS promotions (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    -- percentage: value คือเปอร์เซ็นต์ที่ลด
+    -- fixed_amount: value คือจำนวนเงินที่ลดต่อเล่ม
+    -- buy_x_get_y: ซื้อ buy_quantity เล่ม แถม get_quantity เล่ม (เล่มเดียวกัน) คิดในตะกร้าเท่านั้น
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('percentage', 'fixed_amount', 'buy_x_get_y')),
+    value DECIMAL(10,2) NOT NULL DEFAULT 0,
+    buy_quantity INTEGER CHECK (buy_quantity > 0),
+    get_quantity INTEGER CHECK (get_quantity > 0),
+    -- applies_to_all ใช้กับหนังสือทุกเล่ม ไม่อย่างนั้นใช้กับเป้าหมายใน promotion_targets เท่านั้น
+    applies_to_all BOOLEAN NOT NULL DEFAULT FALSE,
+    requires_coupon BOOLEAN NOT NULL DEFAULT FALSE,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    starts_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ends_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (ends_at IS NULL OR ends_at > starts_at),
+    CHECK (kind <> 'percentage' OR (value > 0 AND value <= 100)),
+    CHECK (kind <> 'fixed_amount' OR value > 0),
+    CHECK ((kind = 'buy_x_get_y') = (buy_quantity IS NOT NULL AND get_quantity IS NOT NULL))
+);
+CREATE INDEX IF NOT EXISTS idx_promotions_active ON promotions(starts_at, ends_at) WHERE is_active;
+
+CREATE TRIGGER update_promotions_modtime BEFORE UPDATE ON promotions
+FOR EACH ROW EXECUTE FUNCTION update_modified_column();
+
+-- เป้าหมายของโปรโมชัน: หนังสือ หมวดหมู่ หรือผู้แต่ง อย่างใดอย่างหนึ่งต่อแถว
+CREATE TABLE IF NOT EXISTS promotion_targets (
+    id SERIAL PRIMARY KEY,
+    promotion_id INTEGER NOT NULL REFERENCES promotions(id) ON DELETE CASCADE,
+    book_id INTEGER REFERENCES books(id) ON DELETE CASCADE,
+    category_id INTEGER REFERENCES categories(id) ON DELETE CASCADE,
+    author_id INTEGER REFERENCES authors(id) ON DELETE CASCADE,
+    CHECK (num_nonnulls(book_id, category_id, author_id) = 1)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_promotion_targets_book ON promotion_targets(promotion_id, book_id) WHERE book_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_promotion_targets_category ON promotion_targets(promotion_id, category_id) WHERE category_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_promotion_targets_author ON promotion_targets(promotion_id, author_id) WHERE author_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_promotion_targets_book_id ON promotion_targets(book_id);
+CREATE INDEX IF NOT EXISTS idx_promotion_targets_category_id ON promotion_targets(category_id);
+CREATE INDEX IF NOT EXISTS idx_promotion_targets_author_id ON promotion_targets(author_id);
+
+-- โปรโมชันที่กำลังใช้งานและครอบคลุมหนังสือเล่มนี้
+-- รับ category_id เป็น argument (ไม่อ่านจาก books) เพื่อให้ใช้ใน RETURNING ของ INSERT/UPDATE ได้
+CREATE OR REPLACE FUNCTION book_promotions(target_book_id INTEGER, target_category_id INTEGER)
+RETURNS SETOF promotions AS $$
+    SELECT p.*
+    FROM promotions p
+    WHERE p.is_active
+      AND p.starts_at <= NOW()
+      AND (p.ends_at IS NULL OR p.ends_at > NOW())
+      AND (p.applies_to_all OR EXISTS (
+          SELECT 1 FROM promotion_targets t
+          WHERE t.promotion_id = p.id
+            AND (t.book_id = target_book_id
+                 OR t.category_id = target_category_id
+                 OR t.author_id IN (SELECT ba.author_id FROM book_authors ba WHERE ba.book_id = target_book_id))
+      ));
+$$ LANGUAGE sql STABLE;
+
+-- ราคาต่อเล่มหลังใช้โปรโมชันแบบ percentage / fixed_amount (ไม่ต่ำกว่า 0)
+CREATE OR REPLACE FUNCTION promotion_unit_price(price NUMERIC, kind TEXT, value NUMERIC)
+RETURNS NUMERIC AS $$
+    SELECT CASE kind
+        WHEN 'percentage' THEN ROUND(price * (100 - value) / 100, 2)
+        WHEN 'fixed_amount' THEN GREATEST(price - value, 0)
+        ELSE price
+    END;
+$$ LANGUAGE sql IMMUTABLE;
+
+-- ราคาขายคือราคาต่ำสุดจากโปรโมชันอัตโนมัติ (ไม่ใช้คูปอง) ที่ครอบคลุมเล่มนี้ โปรโมชันไม่ซ้อนกัน
+CREATE OR REPLACE FUNCTION book_sale_price(target_book_id INTEGER, target_category_id INTEGER, list_price NUMERIC)
+RETURNS NUMERIC AS $$
+    SELECT LEAST(COALESCE(MIN(promotion_unit_price(list_price, p.kind, p.value)), list_price), list_price)
+    FROM book_promotions(target_book_id, target_category_id) p
+    WHERE NOT p.requires_coupon AND p.kind IN ('percentage', 'fixed_amount');
+$$ LANGUAGE sql STABLE;
+
+-- ส่วนลดเป็นเปอร์เซ็นต์ (ปัดเป็นจำนวนเต็ม) ของราคาขายเทียบกับราคาปกติ
+CREATE OR REPLACE FUNCTION book_discount(target_book_id INTEGER, target_category_id INTEGER, list_price NUMERIC)
+RETURNS INTEGER AS $$
+    SELECT CASE WHEN list_price > 0
+                THEN ROUND((list_price - book_sale_price(target_book_id, target_category_id, list_price)) * 100 / list_price)::INTEGER
+                ELSE 0 END;
+$$ LANGUAGE sql STABLE;
+
+-- ย้ายส่วนลดที่กรอกไว้ในแต่ละเล่มเป็นโปรโมชัน fixed_amount ของเล่มนั้น ราคาขายจึงเท่าเดิม
+-- price เดิมเป็นราคาหลังลดแล้ว เปลี่ยนเป็นราคาปกติ (original_price หรือคำนวณย้อนจาก discount)
+DO $$
+DECLARE
+    r RECORD;
+    new_promotion_id INTEGER;
+BEGIN
+    FOR r IN
+        SELECT id, title, price,
+               CASE WHEN original_price > price THEN original_price
+                    ELSE ROUND(price * 100 / (100 - discount), 2) END AS list_price
+        FROM books
+        WHERE price IS NOT NULL
+          AND (original_price > price OR COALESCE(discount, 0) BETWEEN 1 AND 99)
+    LOOP
+        INSERT INTO promotions (name, kind, value)
+        VALUES ('ส่วนลดเดิม: ' || r.title, 'fixed_amount', r.list_price - r.price)
+        RETURNING id INTO new_promotion_id;
+        INSERT INTO promotion_targets (promotion_id, book_id) VALUES (new_promotion_id, r.id);
+        UPDATE books SET price = r.list_price WHERE id = r.id;
+    END LOOP;
+END $$;
+
+ALTER TABLE books DROP COLUMN IF EXISTS original_price;
+ALTER TABLE books DROP COLUMN IF EXISTS discount;
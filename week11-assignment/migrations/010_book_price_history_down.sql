@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS book_authors_sale_price_trigger ON book_authors;
+DROP FUNCTION IF EXISTS record_book_authors_sale_price();
+DROP TRIGGER IF EXISTS books_price_history_trigger ON books;
+DROP FUNCTION IF EXISTS record_book_price_change();
+DROP FUNCTION IF EXISTS record_book_sale_prices(INTEGER);
+DROP FUNCTION IF EXISTS book_lowest_price_30d(INTEGER, NUMERIC);
+DROP TABLE IF EXISTS book_price_history;
@@ -0,0 +1,135 @@
+-- ประวัติราคาของหนังสือแต่ละเล่ม: ราคาปกติ (books.price) และราคาขายหลังโปรโมชัน (book_sale_price)
+-- แถวใหม่ถูกบันทึกเมื่อราคาปกติ หมวดหมู่ หรือผู้แต่งเปลี่ยน (trigger) และเมื่อโปรโมชันเปลี่ยน
+-- ผ่าน record_book_sale_prices ซึ่ง service ที่จัดการโปรโมชันเรียกตอนแก้โปรโมชันและเป็นรอบ
+-- เพื่อจับโปรโมชันที่เริ่มหรือหมดเวลาตาม starts_at / ends_at
+-- changed_by คือ users.id ของ week13-lab6 (FK เพิ่มใน migration ของ lab6) service ที่รู้ตัวผู้แก้
+-- ตั้งค่า app.user_id ใน transaction ก่อนแก้ราคา (SELECT set_config('app.user_id', '42', true))
+CREATE TABLE IF NOT EXISTS book_price_history (
+    id SERIAL PRIMARY KEY,
+    book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    old_price DECIMAL(10,2),
+    price DECIMAL(10,2) NOT NULL,
+    sale_price DECIMAL(10,2) NOT NULL,
+    changed_by INTEGER,
+    effective_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_book_price_history_book ON book_price_history(book_id, effective_at DESC);
+
+-- บันทึกราคาขายของหนังสือที่ต่างจากแถวล่าสุดในประวัติ (target_book_id เป็น NULL คือทุกเล่ม)
+-- คืนจำนวนหนังสือที่บันทึก
+CREATE OR REPLACE FUNCTION record_book_sale_prices(target_book_id INTEGER)
+RETURNS INTEGER AS $$
+    WITH priced AS (
+        SELECT b.id, b.price, book_sale_price(b.id, b.category_id, b.price) AS sale_price
+        FROM books b
+        WHERE b.price IS NOT NULL AND (target_book_id IS NULL OR b.id = target_book_id)
+    ), recorded AS (
+        INSERT INTO book_price_history (book_id, old_price, price, sale_price, changed_by)
+        SELECT p.id, latest.price, p.price, p.sale_price,
+               NULLIF(current_setting('app.user_id', true), '')::INTEGER
+        FROM priced p
+        LEFT JOIN LATERAL (
+            SELECT h.price, h.sale_price FROM book_price_history h
+            WHERE h.book_id = p.id
+            ORDER BY h.effective_at DESC, h.id DESC
+            LIMIT 1
+        ) latest ON TRUE
+        WHERE latest.price IS DISTINCT FROM p.price OR latest.sale_price IS DISTINCT FROM p.sale_price
+        RETURNING 1
+    )
+    SELECT COUNT(*)::INTEGER FROM recorded;
+$$ LANGUAGE sql;
+
+CREATE OR REPLACE FUNCTION record_book_price_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.price IS NULL THEN
+        RETURN NULL;
+    END IF;
+    -- เปลี่ยนหมวดหมู่อย่างเดียว: บันทึกเฉพาะเมื่อราคาขายเปลี่ยนตามโปรโมชันของหมวด
+    IF TG_OP = 'UPDATE' AND NEW.price IS NOT DISTINCT FROM OLD.price THEN
+        PERFORM record_book_sale_prices(NEW.id);
+        RETURN NULL;
+    END IF;
+    INSERT INTO book_price_history (book_id, old_price, price, sale_price, changed_by)
+    VALUES (NEW.id,
+            CASE WHEN TG_OP = 'UPDATE' THEN OLD.price END,
+            NEW.price,
+            book_sale_price(NEW.id, NEW.category_id, NEW.price),
+            NULLIF(current_setting('app.user_id', true), '')::INTEGER);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER books_price_history_trigger
+AFTER INSERT OR UPDATE OF price, category_id ON books
+FOR EACH ROW
+EXECUTE FUNCTION record_book_price_change();
+
+-- โปรโมชันตามผู้แต่งทำให้ราคาขายเปลี่ยนเมื่อ book_authors เปลี่ยน
+-- ทำตอน commit เพราะ sync_book_authors ลบแล้วใส่ใหม่ จะได้ไม่บันทึกราคาระหว่างทาง
+CREATE OR REPLACE FUNCTION record_book_authors_sale_price()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM record_book_sale_prices(CASE WHEN TG_OP = 'DELETE' THEN OLD.book_id ELSE NEW.book_id END);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER book_authors_sale_price_trigger
+AFTER INSERT OR DELETE ON book_authors
+DEFERRABLE INITIALLY DEFERRED
+FOR EACH ROW
+EXECUTE FUNCTION record_book_authors_sale_price();
+
+-- ราคาขายต่ำสุดใน 30 วันก่อนที่ราคาขายปัจจุบันจะเริ่มมีผล (ราคาอ้างอิงของส่วนลดที่แสดงอยู่)
+-- ไม่นับราคาปัจจุบัน ระหว่างโปรโมชันจึงได้ราคาก่อนลด ไม่ใช่ราคาที่ลดแล้ว คืน NULL ถ้าไม่มีประวัติก่อนหน้า
+-- รับราคาขายปัจจุบันเป็น argument เพื่อให้ใช้ใน RETURNING ของ UPDATE ได้ (แถวประวัติใหม่ยังไม่ถูกเขียนตอนนั้น)
+CREATE OR REPLACE FUNCTION book_lowest_price_30d(target_book_id INTEGER, current_sale_price NUMERIC)
+RETURNS NUMERIC AS $$
+DECLARE
+    changed_at TIMESTAMP WITH TIME ZONE;
+    changed_id INTEGER;
+    started_at TIMESTAMP WITH TIME ZONE;
+    started_id INTEGER;
+    lowest NUMERIC;
+BEGIN
+    -- แถวล่าสุดที่ราคาขายต่างจากปัจจุบัน ราคาปัจจุบันเริ่มมีผลที่แถวถัดจากนั้น
+    SELECT effective_at, id INTO changed_at, changed_id
+    FROM book_price_history
+    WHERE book_id = target_book_id AND sale_price IS DISTINCT FROM current_sale_price
+    ORDER BY effective_at DESC, id DESC
+    LIMIT 1;
+
+    SELECT effective_at, id INTO started_at, started_id
+    FROM book_price_history
+    WHERE book_id = target_book_id
+      AND (changed_id IS NULL OR (effective_at, id) > (changed_at, changed_id))
+    ORDER BY effective_at, id
+    LIMIT 1;
+    -- ยังไม่มีแถวของราคาปัจจุบัน: เพิ่งเปลี่ยนใน statement นี้
+    IF started_at IS NULL THEN
+        started_at := NOW();
+    END IF;
+
+    SELECT MIN(h.sale_price) INTO lowest
+    FROM (
+        SELECT sale_price FROM book_price_history
+        WHERE book_id = target_book_id
+          AND effective_at > started_at - INTERVAL '30 days'
+          AND (started_id IS NULL OR (effective_at, id) < (started_at, started_id))
+        UNION ALL
+        (SELECT sale_price FROM book_price_history
+         WHERE book_id = target_book_id AND effective_at <= started_at - INTERVAL '30 days'
+         ORDER BY effective_at DESC, id DESC
+         LIMIT 1)
+    ) h;
+    RETURN lowest;
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+-- ราคาปัจจุบันของหนังสือที่มีอยู่แล้วเป็นจุดเริ่มของประวัติ
+INSERT INTO book_price_history (book_id, price, sale_price, effective_at)
+SELECT id, price, book_sale_price(id, category_id, price), COALESCE(created_at, CURRENT_TIMESTAMP)
+FROM books
+WHERE price IS NOT NULL;
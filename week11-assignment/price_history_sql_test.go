@@ -0,0 +1,170 @@
+package main
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	_ "github.com/lib/pq"
+)
+
+// openTestDB เชื่อมต่อฐานข้อมูลที่รัน migration ครบแล้ว (TEST_DATABASE_URL) ถ้าไม่ได้ตั้งค่าจะข้าม test
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// withTestTx รัน fn ใน transaction ที่ rollback เสมอ ข้อมูลของ test จึงไม่ค้างในฐานข้อมูล
+func withTestTx(t *testing.T, conn *sql.DB, fn func(tx *sql.Tx)) {
+	t.Helper()
+	tx, err := conn.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	fn(tx)
+}
+
+// priceRow คือแถวใน book_price_history ที่มีผลเมื่อ daysAgo วันก่อน
+type priceRow struct {
+	daysAgo   int
+	price     float64
+	salePrice float64
+}
+
+// insertBookWithHistory สร้างหนังสือราคา listPrice แล้วแทนประวัติที่ trigger เขียนด้วย rows
+func insertBookWithHistory(t *testing.T, tx *sql.Tx, listPrice float64, rows []priceRow) int {
+	t.Helper()
+	var bookID int
+	err := tx.QueryRow(`
+		INSERT INTO books (title, author, price)
+		VALUES ('Lowest price test', 'Price Test Author', $1)
+		RETURNING id`, listPrice).Scan(&bookID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("DELETE FROM book_price_history WHERE book_id = $1", bookID); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		_, err := tx.Exec(`
+			INSERT INTO book_price_history (book_id, price, sale_price, effective_at)
+			VALUES ($1, $2, $3, NOW() - make_interval(days => $4))`,
+			bookID, row.price, row.salePrice, row.daysAgo)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return bookID
+}
+
+// addBookPromotion สร้างโปรโมชัน percentage ที่ใช้กับหนังสือเล่มเดียว เริ่มตอนนี้
+func addBookPromotion(t *testing.T, tx *sql.Tx, bookID int, percent float64) {
+	t.Helper()
+	var promotionID int
+	err := tx.QueryRow(`
+		INSERT INTO promotions (name, kind, value)
+		VALUES ('Lowest price test', 'percentage', $1)
+		RETURNING id`, percent).Scan(&promotionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("INSERT INTO promotion_targets (promotion_id, book_id) VALUES ($1, $2)", promotionID, bookID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// lowestPrice30d คืน book_lowest_price_30d ของหนังสือโดยใช้ราคาขายปัจจุบันจาก book_sale_price
+func lowestPrice30d(t *testing.T, tx *sql.Tx, bookID int) (salePrice float64, lowest sql.NullFloat64) {
+	t.Helper()
+	err := tx.QueryRow(`
+		SELECT `+bookSalePriceExpr+`, `+bookLowestPriceExpr+`
+		FROM `+bookFrom+` WHERE books.id = $1`, bookID).Scan(&salePrice, &lowest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return salePrice, lowest
+}
+
+func TestLowestPrice30dIgnoresCurrentPromotion(t *testing.T) {
+	conn := openTestDB(t)
+	withTestTx(t, conn, func(tx *sql.Tx) {
+		bookID := insertBookWithHistory(t, tx, 100, []priceRow{{daysAgo: 60, price: 100, salePrice: 100}})
+		addBookPromotion(t, tx, bookID, 10)
+
+		salePrice, lowest := lowestPrice30d(t, tx, bookID)
+		if salePrice != 90 {
+			t.Fatalf("sale price = %v, want 90", salePrice)
+		}
+		if !lowest.Valid || lowest.Float64 != 100 {
+			t.Fatalf("lowest_price_30d = %v, want 100 (the price before the promotion)", lowest)
+		}
+
+		// หลังบันทึกราคาขายลงประวัติแล้ว ผลต้องเหมือนเดิม
+		if _, err := tx.Exec("SELECT record_book_sale_prices($1)", bookID); err != nil {
+			t.Fatal(err)
+		}
+		if _, lowest := lowestPrice30d(t, tx, bookID); !lowest.Valid || lowest.Float64 != 100 {
+			t.Fatalf("lowest_price_30d after recording = %v, want 100", lowest)
+		}
+	})
+}
+
+func TestLowestPrice30dIncludesEarlierPromotion(t *testing.T) {
+	conn := openTestDB(t)
+	withTestTx(t, conn, func(tx *sql.Tx) {
+		// ขายที่ 80 เมื่อสัปดาห์ก่อน แล้วกลับเป็น 100 ตอนนี้ลดเหลือ 90
+		bookID := insertBookWithHistory(t, tx, 100, []priceRow{
+			{daysAgo: 60, price: 100, salePrice: 100},
+			{daysAgo: 10, price: 100, salePrice: 80},
+			{daysAgo: 7, price: 100, salePrice: 100},
+		})
+		addBookPromotion(t, tx, bookID, 10)
+
+		if _, lowest := lowestPrice30d(t, tx, bookID); !lowest.Valid || lowest.Float64 != 80 {
+			t.Fatalf("lowest_price_30d = %v, want 80", lowest)
+		}
+	})
+}
+
+func TestLowestPrice30dMeasuresBeforeReductionStarted(t *testing.T) {
+	conn := openTestDB(t)
+	withTestTx(t, conn, func(tx *sql.Tx) {
+		// ลดเหลือ 90 มา 40 วันแล้ว ราคาอ้างอิงคือ 30 วันก่อนเริ่มลด ไม่ใช่ 30 วันล่าสุด
+		bookID := insertBookWithHistory(t, tx, 100, []priceRow{
+			{daysAgo: 100, price: 100, salePrice: 70},
+			{daysAgo: 80, price: 100, salePrice: 100},
+			{daysAgo: 40, price: 100, salePrice: 90},
+		})
+		addBookPromotion(t, tx, bookID, 10)
+
+		if _, lowest := lowestPrice30d(t, tx, bookID); !lowest.Valid || lowest.Float64 != 100 {
+			t.Fatalf("lowest_price_30d = %v, want 100", lowest)
+		}
+	})
+}
+
+func TestLowestPrice30dWithoutEarlierHistory(t *testing.T) {
+	conn := openTestDB(t)
+	withTestTx(t, conn, func(tx *sql.Tx) {
+		// ลดราคาตั้งแต่วันแรกที่มีประวัติ จึงไม่มีราคาอ้างอิง
+		bookID := insertBookWithHistory(t, tx, 100, []priceRow{{daysAgo: 5, price: 100, salePrice: 90}})
+		addBookPromotion(t, tx, bookID, 10)
+
+		if _, lowest := lowestPrice30d(t, tx, bookID); lowest.Valid {
+			t.Fatalf("lowest_price_30d = %v, want NULL", lowest.Float64)
+		}
+	})
+}
@@ -0,0 +1,11 @@
+-- 23. ลบ refresh token ที่เคยถูกบันทึกไว้ใน audit log ตอน rotate
+-- details เก่ามี token จริง ผู้ที่อ่านหรือ export audit log ได้จะนำไปใช้แทนเจ้าของ session ได้
+UPDATE audit_logs
+SET details = details - 'old_refresh_token' - 'new_refresh_token'
+WHERE action = 'refresh' AND resource = 'auth'
+  AND (details ? 'old_refresh_token' OR details ? 'new_refresh_token');
+
+-- revoke token ที่อาจรั่วไปแล้ว ผู้ใช้ต้อง login ใหม่ครั้งเดียว
+UPDATE refresh_tokens
+SET revoked_at = NOW()
+WHERE revoked_at IS NULL;
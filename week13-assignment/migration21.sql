@@ -0,0 +1,7 @@
+-- 24. Soft delete สำหรับ books
+-- DELETE /books/:id ของ service นี้ตั้ง deleted_at แทนการลบจริง
+-- คอลัมน์เดียวกับ week13-lab6/migration13.sql และ week11-assignment/migrations/005 (รันซ้ำได้)
+-- การดูถังขยะ กู้คืน และลบถาวรอยู่ที่ week13-lab6 ซึ่งตรวจสิทธิ์ books:purge
+ALTER TABLE books ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_books_deleted_at ON books(deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,14 @@
+-- 27. Password reset tokens
+-- /auth/forgot-password และ /auth/reset-password ของ service นี้ใช้ตารางเดียวกับ week13-lab6/migration10.sql
+-- (รันซ้ำได้ ถ้ารัน migration ของ lab6 แล้วจะไม่เปลี่ยนอะไร)
+-- token ใช้ได้ครั้งเดียวและอายุสั้น เก็บเฉพาะ SHA-256
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_reset_user ON password_reset_tokens(user_id);
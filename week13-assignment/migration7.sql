@@ -0,0 +1,7 @@
+-- 10. Refresh token families
+-- ทุก token ที่ rotate ต่อกันมาจาก login ครั้งเดียวกันใช้ family_id เดียวกัน
+-- ใช้ revoke ทั้งสายเมื่อตรวจพบการนำ token ที่ถูก rotate แล้วกลับมาใช้ซ้ำ
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS family_id VARCHAR(64);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON refresh_tokens(family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_replaced_by ON refresh_tokens(replaced_by);
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+// insertTrashedBook สร้างหนังสือที่ถูกย้ายลงถังขยะเมื่อ age ที่แล้ว (commit จริง เพราะ purge ใช้ db ตรง)
+func insertTrashedBook(t *testing.T, conn *sql.DB, age time.Duration) int {
+	t.Helper()
+	var bookID int
+	err := conn.QueryRow(`
+		INSERT INTO books (title, author, price, deleted_at)
+		VALUES ('Trash test', 'Trash Test Author', 100, $1)
+		RETURNING id`, time.Now().Add(-age)).Scan(&bookID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Exec("DELETE FROM books WHERE id = $1", bookID)
+		conn.Exec("DELETE FROM audit_logs WHERE resource = 'books' AND resource_id = $1", bookID)
+	})
+	return bookID
+}
+
+func TestPurgeExpiredBooks(t *testing.T) {
+	conn := openTestDB(t)
+	oldDB, oldRetention := db, bookTrashRetention
+	db, bookTrashRetention = conn, time.Hour
+	t.Cleanup(func() { db, bookTrashRetention = oldDB, oldRetention })
+
+	expired := insertTrashedBook(t, conn, 2*time.Hour)
+	recent := insertTrashedBook(t, conn, time.Minute)
+
+	purged, err := purgeExpiredBooks(0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := map[int]bool{}
+	for _, id := range purged {
+		found[id] = true
+	}
+	if !found[expired] || found[recent] {
+		t.Fatalf("purged = %v, want %d and not %d", purged, expired, recent)
+	}
+
+	var exists bool
+	if err := conn.QueryRow("SELECT EXISTS (SELECT 1 FROM books WHERE id = $1)", recent).Scan(&exists); err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Fatal("book still inside the retention period was purged")
+	}
+
+	var audits int
+	err = conn.QueryRow(`
+		SELECT COUNT(*) FROM audit_logs
+		WHERE action = 'purge' AND resource = 'books' AND resource_id = $1 AND user_id IS NULL`,
+		expired).Scan(&audits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if audits != 1 {
+		t.Fatalf("audit rows for the purged book = %d, want 1", audits)
+	}
+}
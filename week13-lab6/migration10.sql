@@ -0,0 +1,12 @@
+-- 13. Password reset tokens
+-- token ใช้ได้ครั้งเดียวและอายุสั้น เก็บเฉพาะ SHA-256 เหมือน email_verification_tokens
+CREATE TABLE password_reset_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_password_reset_user ON password_reset_tokens(user_id);
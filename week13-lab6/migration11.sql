@@ -0,0 +1,19 @@
+-- 14. Two-factor authentication (TOTP)
+-- totp_secret เก็บแบบเข้ารหัส (AES-GCM) ส่วน recovery code เก็บเฉพาะ SHA-256
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE mfa_recovery_codes (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_mfa_recovery_codes_user ON mfa_recovery_codes(user_id);
+
+-- นโยบาย 2FA ต่อ role: admin ต้องใช้ 2FA
+ALTER TABLE roles ADD COLUMN IF NOT EXISTS require_mfa BOOLEAN NOT NULL DEFAULT false;
+UPDATE roles SET require_mfa = true WHERE name = 'admin';
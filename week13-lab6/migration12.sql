@@ -0,0 +1,39 @@
+-- 15. แจ้ง API ทุก instance เมื่อสิทธิ์เปลี่ยน เพื่อล้าง permission cache
+-- user_roles เปลี่ยน: ส่ง "user:<id>" ล้างเฉพาะ user นั้น
+-- role_permissions เปลี่ยน: ส่ง "*" ล้างทั้งหมด (กระทบทุก user ที่มี role นั้น)
+CREATE OR REPLACE FUNCTION notify_user_roles_changed()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        PERFORM pg_notify('permissions_changed', 'user:' || OLD.user_id);
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        PERFORM pg_notify('permissions_changed', 'user:' || NEW.user_id);
+    END IF;
+    RETURN NULL;
+END;
+$$ language 'plpgsql';
+
+CREATE OR REPLACE FUNCTION notify_role_permissions_changed()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('permissions_changed', '*');
+    RETURN NULL;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER user_roles_notify
+AFTER INSERT OR UPDATE OR DELETE ON user_roles
+FOR EACH ROW
+EXECUTE FUNCTION notify_user_roles_changed();
+
+CREATE TRIGGER role_permissions_notify
+AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON role_permissions
+FOR EACH STATEMENT
+EXECUTE FUNCTION notify_role_permissions_changed();
+
+-- การเปลี่ยนชื่อ permission ก็ทำให้ cache (ซึ่งเก็บตามชื่อ) ผิดได้
+CREATE TRIGGER permissions_notify
+AFTER UPDATE OR DELETE OR TRUNCATE ON permissions
+FOR EACH STATEMENT
+EXECUTE FUNCTION notify_role_permissions_changed();
@@ -0,0 +1,16 @@
+-- 16. Soft delete สำหรับ books
+-- DELETE /books/:id แค่ตั้ง deleted_at หนังสือจะถูกลบจริงเมื่ออยู่ในถังขยะเกิน retention
+ALTER TABLE books ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_books_deleted_at ON books(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- ลบถาวรได้เฉพาะผู้ที่มี books:purge (admin)
+INSERT INTO permissions (name, description, resource, action) VALUES
+('books:purge', 'Can permanently purge deleted books', 'books', 'purge')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name = 'books:purge'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,39 @@
+-- 17. รีวิวจากลูกค้า
+-- books.rating และ books.reviews_count คำนวณจากตารางนี้ใน transaction เดียวกับการเขียนรีวิว
+ALTER TABLE books ADD COLUMN IF NOT EXISTS rating DECIMAL(3,2) DEFAULT 0;
+ALTER TABLE books ADD COLUMN IF NOT EXISTS reviews_count INTEGER DEFAULT 0;
+
+CREATE TABLE reviews (
+    id SERIAL PRIMARY KEY,
+    book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    rating SMALLINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    body TEXT NOT NULL DEFAULT '',
+    helpful_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    -- หนึ่งคนรีวิวหนังสือหนึ่งเล่มได้ครั้งเดียว
+    UNIQUE (book_id, user_id)
+);
+
+CREATE INDEX idx_reviews_book_created ON reviews(book_id, created_at DESC);
+CREATE INDEX idx_reviews_user ON reviews(user_id);
+
+-- โหวตว่ารีวิวมีประโยชน์ หนึ่งคนโหวตรีวิวหนึ่งได้ครั้งเดียว
+CREATE TABLE review_votes (
+    review_id INTEGER NOT NULL REFERENCES reviews(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (review_id, user_id)
+);
+
+-- ลบรีวิวของคนอื่นได้เฉพาะ reviews:moderate (admin, editor)
+INSERT INTO permissions (name, description, resource, action) VALUES
+('reviews:moderate', 'Can delete any review', 'reviews', 'moderate')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name IN ('admin', 'editor') AND p.name = 'reviews:moderate'
+ON CONFLICT DO NOTHING;
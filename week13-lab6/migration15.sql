@@ -0,0 +1,38 @@
+-- 18. Inventory และการจองสินค้า
+-- on_hand คือจำนวนที่มีอยู่จริง reserved คือผลรวมของ reservation ที่ยัง active
+-- ทุกการเปลี่ยนแปลงต้องล็อกแถวของ book_inventory ก่อน (SELECT ... FOR UPDATE) จึงขายเกินไม่ได้
+-- (ตารางเดียวกับ week11-assignment/migrations/008 ถ้ารันฝั่งนั้นแล้วคำสั่งแรกจะข้ามไป)
+CREATE TABLE IF NOT EXISTS book_inventory (
+    book_id INTEGER PRIMARY KEY REFERENCES books(id) ON DELETE CASCADE,
+    on_hand INTEGER NOT NULL DEFAULT 0 CHECK (on_hand >= 0),
+    reserved INTEGER NOT NULL DEFAULT 0 CHECK (reserved >= 0),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (reserved <= on_hand)
+);
+
+-- reservation มีอายุจำกัด หมดเวลาแล้วถูกปล่อยคืน stock อัตโนมัติ
+CREATE TABLE stock_reservations (
+    id SERIAL PRIMARY KEY,
+    book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'active'
+        CHECK (status IN ('active', 'released', 'expired', 'committed')),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    released_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_stock_reservations_expiry ON stock_reservations(expires_at) WHERE status = 'active';
+CREATE INDEX idx_stock_reservations_user ON stock_reservations(user_id) WHERE status = 'active';
+
+-- ปรับยอด stock ได้เฉพาะ inventory:manage (admin, editor)
+INSERT INTO permissions (name, description, resource, action) VALUES
+('inventory:manage', 'Can adjust stock levels', 'inventory', 'manage')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name IN ('admin', 'editor') AND p.name = 'inventory:manage'
+ON CONFLICT DO NOTHING;
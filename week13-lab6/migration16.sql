@@ -0,0 +1,28 @@
+-- 19. ตะกร้าสินค้า
+-- cart เป็นของ user (user_id) หรือของ guest (SHA-256 ของ token ใน cookie cart_session) อย่างใดอย่างหนึ่ง
+-- cart ของ guest ถูกรวมเข้า cart ของ user ตอน login แล้วลบทิ้ง
+CREATE TABLE carts (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    guest_token_hash VARCHAR(64) UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((user_id IS NULL) <> (guest_token_hash IS NULL))
+);
+
+CREATE INDEX idx_carts_guest_updated ON carts(updated_at) WHERE guest_token_hash IS NOT NULL;
+
+-- price_at_add คือราคาที่ผู้ใช้เห็นตอนเพิ่ม/แก้จำนวนครั้งล่าสุด ใช้บอกว่าราคาเปลี่ยนไปแล้ว
+CREATE TABLE cart_items (
+    cart_id INTEGER NOT NULL REFERENCES carts(id) ON DELETE CASCADE,
+    book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL CHECK (quantity BETWEEN 1 AND 100),
+    price_at_add DECIMAL(10,2) NOT NULL,
+    added_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (cart_id, book_id)
+);
+
+-- ราคาในตะกร้าคำนวณจาก price และ discount ของ books
+ALTER TABLE books ADD COLUMN IF NOT EXISTS original_price DECIMAL(10,2);
+ALTER TABLE books ADD COLUMN IF NOT EXISTS discount INTEGER DEFAULT 0;
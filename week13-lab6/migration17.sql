@@ -0,0 +1,75 @@
+-- 20. คำสั่งซื้อ
+-- order เก็บสำเนาชื่อหนังสือและราคา ณ ตอนสั่งซื้อ ไม่ขึ้นกับ books อีกต่อไป
+-- book_id เป็น NULL ได้: หนังสือที่ถูก purge จากถังขยะ (books.deleted_at, migration13.sql) ไม่ทำให้ประวัติคำสั่งซื้อหาย
+CREATE TABLE orders (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'paid', 'shipped', 'delivered', 'cancelled', 'refunded')),
+    subtotal DECIMAL(10,2) NOT NULL,
+    discount_total DECIMAL(10,2) NOT NULL DEFAULT 0,
+    total DECIMAL(10,2) NOT NULL,
+    shipping_address TEXT NOT NULL,
+    tracking_number VARCHAR(100),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_orders_user_created ON orders(user_id, created_at DESC);
+CREATE INDEX idx_orders_status_created ON orders(status, created_at);
+
+CREATE TABLE order_items (
+    id SERIAL PRIMARY KEY,
+    order_id INTEGER NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    book_id INTEGER REFERENCES books(id) ON DELETE SET NULL,
+    title VARCHAR(255) NOT NULL,
+    author VARCHAR(255) NOT NULL DEFAULT '',
+    isbn VARCHAR(50) NOT NULL DEFAULT '',
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    unit_price DECIMAL(10,2) NOT NULL,
+    list_price DECIMAL(10,2) NOT NULL,
+    line_total DECIMAL(10,2) NOT NULL
+);
+
+CREATE INDEX idx_order_items_order ON order_items(order_id);
+
+-- ประวัติการเปลี่ยนสถานะ (from_status เป็น NULL ตอนสร้าง order)
+CREATE TABLE order_status_history (
+    id SERIAL PRIMARY KEY,
+    order_id INTEGER NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    from_status VARCHAR(20),
+    to_status VARCHAR(20) NOT NULL,
+    changed_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_order_status_history_order ON order_status_history(order_id, created_at);
+
+-- Orders permissions (ลูกค้าสั่งซื้อและดู order ของตัวเองได้โดยไม่ต้องมี permission)
+INSERT INTO permissions (name, description, resource, action) VALUES
+('orders:read', 'Can view all orders and the order queue', 'orders', 'read'),
+('orders:update', 'Can move orders through paid, shipped, delivered and cancelled', 'orders', 'update'),
+('orders:refund', 'Can refund orders', 'orders', 'refund')
+ON CONFLICT (name) DO NOTHING;
+
+-- Admin: ทุก orders permissions
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name IN ('orders:read', 'orders:update', 'orders:refund')
+ON CONFLICT DO NOTHING;
+
+-- Editor: ดูแลคิว order แต่คืนเงินไม่ได้
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name = 'editor' AND p.name IN ('orders:read', 'orders:update')
+ON CONFLICT DO NOTHING;
+
+-- Viewer: read-only
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name = 'viewer' AND p.name = 'orders:read'
+ON CONFLICT DO NOTHING;
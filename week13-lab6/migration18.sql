@@ -0,0 +1,54 @@
+-- 21. โปรโมชันและคูปอง
+-- ตาราง promotions และฟังก์ชันคำนวณราคา (book_promotions, book_sale_price, book_discount)
+-- มาจาก week11-assignment/migrations/009_promotions_up.sql ต้องรันไฟล์นั้นก่อน
+-- หลังจากนั้น books.price คือราคาปกติ ราคาขายคำนวณจากโปรโมชันที่กำลังใช้งาน
+
+-- คูปองหนึ่งรหัสผูกกับโปรโมชันหนึ่งตัว (ควรเป็นโปรโมชันที่ requires_coupon)
+-- usage_limit / per_user_limit เป็น NULL หมายถึงไม่จำกัด
+CREATE TABLE coupons (
+    id SERIAL PRIMARY KEY,
+    promotion_id INTEGER NOT NULL REFERENCES promotions(id) ON DELETE CASCADE,
+    code VARCHAR(50) NOT NULL,
+    usage_limit INTEGER CHECK (usage_limit > 0),
+    per_user_limit INTEGER CHECK (per_user_limit > 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_coupons_code ON coupons (upper(code));
+CREATE INDEX idx_coupons_promotion ON coupons(promotion_id);
+
+-- การใช้คูปองหนึ่งครั้งต่อหนึ่ง order จำนวนครั้งที่ใช้นับจากตารางนี้
+-- order ที่ถูกยกเลิกจะลบแถวออกเพื่อคืนสิทธิ์ ลบคูปองที่เคยถูกใช้แล้วไม่ได้ (ให้ปิดโปรโมชันแทน)
+CREATE TABLE coupon_redemptions (
+    id SERIAL PRIMARY KEY,
+    coupon_id INTEGER NOT NULL REFERENCES coupons(id) ON DELETE RESTRICT,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    order_id INTEGER NOT NULL UNIQUE REFERENCES orders(id) ON DELETE CASCADE,
+    discount DECIMAL(10,2) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_coupon_redemptions_coupon_user ON coupon_redemptions(coupon_id, user_id);
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS coupon_code VARCHAR(50);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS coupon_discount DECIMAL(10,2) NOT NULL DEFAULT 0;
+
+-- Promotions permissions
+INSERT INTO permissions (name, description, resource, action) VALUES
+('promotions:read', 'Can view promotions and coupons', 'promotions', 'read'),
+('promotions:manage', 'Can create, update and delete promotions and coupons', 'promotions', 'manage')
+ON CONFLICT (name) DO NOTHING;
+
+-- Admin และ Editor: จัดการโปรโมชันได้
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name IN ('admin', 'editor') AND p.name IN ('promotions:read', 'promotions:manage')
+ON CONFLICT DO NOTHING;
+
+-- Viewer: read-only
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name = 'viewer' AND p.name = 'promotions:read'
+ON CONFLICT DO NOTHING;
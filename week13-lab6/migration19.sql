@@ -0,0 +1,7 @@
+-- 22. ประวัติราคาหนังสือ
+-- ตาราง book_price_history (ราคาปกติและราคาขายหลังโปรโมชัน), trigger ที่บันทึกการเปลี่ยนราคา และฟังก์ชัน book_lowest_price_30d
+-- มาจาก week11-assignment/migrations/010_book_price_history_up.sql ต้องรันไฟล์นั้นก่อน
+-- service นี้ตั้งค่า app.user_id ใน transaction ที่แก้ราคา changed_by จึงชี้ไปที่ผู้ใช้ในตาราง users
+ALTER TABLE book_price_history
+    ADD CONSTRAINT book_price_history_changed_by_fkey
+    FOREIGN KEY (changed_by) REFERENCES users(id) ON DELETE SET NULL;
@@ -0,0 +1,12 @@
+-- 25. สิทธิ์จองสินค้า
+-- POST /books/:id/reservations ต้องมี reservations:create (role user ได้ตั้งแต่สมัคร viewer ไม่ได้)
+-- จำนวนที่จองได้ต่อเล่มและต่อ user และ rate limit กำหนดใน service (RESERVATION_MAX_PER_BOOK ฯลฯ)
+INSERT INTO permissions (name, description, resource, action) VALUES
+('reservations:create', 'Can reserve stock for checkout', 'reservations', 'create')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name IN ('admin', 'editor', 'user') AND p.name = 'reservations:create'
+ON CONFLICT DO NOTHING;
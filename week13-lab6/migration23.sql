@@ -0,0 +1,25 @@
+-- 26. บันทึกราคาขายเฉพาะหนังสือที่โปรโมชันมีผล
+-- เหมือน record_book_sale_prices (week11-assignment/migrations/010) แต่รับรายการหนังสือ
+-- การสร้าง แก้ หรือลบโปรโมชันจึงไม่ต้องคำนวณราคาขายของทั้ง catalog (target_book_ids เป็น NULL คือทุกเล่ม)
+CREATE OR REPLACE FUNCTION record_book_sale_prices_in(target_book_ids INTEGER[])
+RETURNS INTEGER AS $$
+    WITH priced AS (
+        SELECT b.id, b.price, book_sale_price(b.id, b.category_id, b.price) AS sale_price
+        FROM books b
+        WHERE b.price IS NOT NULL AND (target_book_ids IS NULL OR b.id = ANY(target_book_ids))
+    ), recorded AS (
+        INSERT INTO book_price_history (book_id, old_price, price, sale_price, changed_by)
+        SELECT p.id, latest.price, p.price, p.sale_price,
+               NULLIF(current_setting('app.user_id', true), '')::INTEGER
+        FROM priced p
+        LEFT JOIN LATERAL (
+            SELECT h.price, h.sale_price FROM book_price_history h
+            WHERE h.book_id = p.id
+            ORDER BY h.effective_at DESC, h.id DESC
+            LIMIT 1
+        ) latest ON TRUE
+        WHERE latest.price IS DISTINCT FROM p.price OR latest.sale_price IS DISTINCT FROM p.sale_price
+        RETURNING 1
+    )
+    SELECT COUNT(*)::INTEGER FROM recorded;
+$$ LANGUAGE sql;
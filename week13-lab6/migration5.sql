@@ -0,0 +1,13 @@
+-- 8. ให้ลบ user ได้โดยไม่ทำให้ประวัติหาย
+-- audit_logs และ user_roles.assigned_by อ้างถึง users(id) แบบไม่มี ON DELETE
+-- ทำให้ DELETE FROM users ล้มเหลวทันทีที่ user เคย login หรือเคยมอบหมาย role
+
+ALTER TABLE audit_logs DROP CONSTRAINT IF EXISTS audit_logs_user_id_fkey;
+ALTER TABLE audit_logs
+    ADD CONSTRAINT audit_logs_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL;
+
+ALTER TABLE user_roles DROP CONSTRAINT IF EXISTS user_roles_assigned_by_fkey;
+ALTER TABLE user_roles
+    ADD CONSTRAINT user_roles_assigned_by_fkey
+    FOREIGN KEY (assigned_by) REFERENCES users(id) ON DELETE SET NULL;
@@ -0,0 +1,3 @@
+-- 9. Index สำหรับค้นหา audit logs ผ่าน /api/v1/audit-logs
+CREATE INDEX IF NOT EXISTS idx_audit_logs_resource ON audit_logs(resource, resource_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_ip ON audit_logs(ip_address);
@@ -0,0 +1,4 @@
+-- 11. Brute-force protection สำหรับ /auth/login
+ALTER TABLE users ADD COLUMN IF NOT EXISTS failed_login_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_failed_login TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP;
@@ -0,0 +1,12 @@
+-- 12. Email verification tokens
+-- เก็บเฉพาะ SHA-256 ของ token ตัวจริงอยู่ในลิงก์ที่ส่งทางอีเมลเท่านั้น
+CREATE TABLE email_verification_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_email_verification_user ON email_verification_tokens(user_id);
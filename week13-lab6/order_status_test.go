@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCanTransitionOrder(t *testing.T) {
+	allowed := map[[2]string]bool{
+		{orderPending, orderPaid}:       true,
+		{orderPending, orderCancelled}:  true,
+		{orderPaid, orderShipped}:       true,
+		{orderPaid, orderRefunded}:      true,
+		{orderShipped, orderDelivered}:  true,
+		{orderDelivered, orderRefunded}: true,
+	}
+	for from := range orderStatuses {
+		for to := range orderStatuses {
+			want := allowed[[2]string{from, to}]
+			if got := canTransitionOrder(from, to); got != want {
+				t.Errorf("canTransitionOrder(%s, %s) = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestOrderTransitionsUseKnownStatuses(t *testing.T) {
+	for from, next := range orderTransitions {
+		if !orderStatuses[from] {
+			t.Errorf("unknown status %q in orderTransitions", from)
+		}
+		for _, to := range next {
+			if !orderStatuses[to] {
+				t.Errorf("unknown status %q reachable from %s", to, from)
+			}
+		}
+	}
+	for _, final := range []string{orderCancelled, orderRefunded} {
+		if len(orderTransitions[final]) != 0 {
+			t.Errorf("%s should be a final status", final)
+		}
+	}
+}
+
+func TestUpdateOrderStatusRejectsInvalidStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, body := range []string{`{}`, `{"status":"pending"}`, `{"status":"lost"}`} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/orders/1/status", bytes.NewBufferString(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		updateOrderStatus(c)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("body %s: status = %d, want 400", body, w.Code)
+		}
+	}
+}
+
+// insertTestOrder สร้าง order สถานะ status ที่มีหนังสือ bookID จำนวน quantity เล่ม
+func insertTestOrder(t *testing.T, tx *sql.Tx, userID, bookID, quantity int, status string) int {
+	t.Helper()
+	var orderID int
+	err := tx.QueryRow(`
+		INSERT INTO orders (user_id, status, subtotal, total, shipping_address)
+		VALUES ($1, $2, 100, 100, 'Test address')
+		RETURNING id`, userID, status).Scan(&orderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tx.Exec(`
+		INSERT INTO order_items (order_id, book_id, title, quantity, unit_price, list_price, line_total)
+		VALUES ($1, $2, 'Stock test', $3, 100, 100, 100 * $3)`, orderID, bookID, quantity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return orderID
+}
+
+func TestChangeOrderStatusRestock(t *testing.T) {
+	conn := openTestDB(t)
+
+	tests := []struct {
+		from, to string
+		restock  bool
+	}{
+		{orderPending, orderCancelled, true},
+		{orderPaid, orderRefunded, true},
+		{orderPending, orderPaid, false},
+		{orderPaid, orderShipped, false},
+		{orderDelivered, orderRefunded, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+"->"+tt.to, func(t *testing.T) {
+			withTestTx(t, conn, func(tx *sql.Tx) {
+				userID := insertTestUser(t, tx)
+				bookID := insertStockedBook(t, tx, 5)
+				orderID := insertTestOrder(t, tx, userID, bookID, 2, tt.from)
+
+				if err := changeOrderStatus(tx, orderID, tt.from, tt.to, userID, "test"); err != nil {
+					t.Fatal(err)
+				}
+
+				want := 5
+				if tt.restock {
+					want = 7
+				}
+				if onHand, _ := stockOf(t, tx, bookID); onHand != want {
+					t.Fatalf("on_hand = %d, want %d", onHand, want)
+				}
+
+				var status string
+				var history int
+				err := tx.QueryRow(`
+					SELECT o.status, (SELECT COUNT(*) FROM order_status_history h
+					                  WHERE h.order_id = o.id AND h.from_status = $2 AND h.to_status = $3)
+					FROM orders o WHERE o.id = $1`, orderID, tt.from, tt.to).Scan(&status, &history)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if status != tt.to || history != 1 {
+					t.Fatalf("status=%s history=%d, want %s and 1", status, history, tt.to)
+				}
+			})
+		})
+	}
+}
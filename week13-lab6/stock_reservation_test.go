@@ -0,0 +1,214 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// openTestDB เชื่อมต่อฐานข้อมูลที่รัน migration ครบแล้ว (TEST_DATABASE_URL) ถ้าไม่ได้ตั้งค่าจะข้าม test
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// withTestTx รัน fn ใน transaction ที่ rollback เสมอ ข้อมูลของ test จึงไม่ค้างในฐานข้อมูล
+func withTestTx(t *testing.T, conn *sql.DB, fn func(tx *sql.Tx)) {
+	t.Helper()
+	tx, err := conn.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	fn(tx)
+}
+
+// insertTestUser สร้าง user ชื่อไม่ซ้ำกับที่มีอยู่ในฐานข้อมูล
+func insertTestUser(t *testing.T, tx *sql.Tx) int {
+	t.Helper()
+	name := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	userID, err := insertUser(tx, name, name+"@example.com", "x", true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return userID
+}
+
+// insertStockedBook สร้างหนังสือที่มี on_hand เท่ากับ onHand
+func insertStockedBook(t *testing.T, tx *sql.Tx, onHand int) int {
+	t.Helper()
+	var bookID int
+	err := tx.QueryRow(`
+		INSERT INTO books (title, author, price)
+		VALUES ('Stock test', 'Stock Test Author', 100)
+		RETURNING id`).Scan(&bookID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("INSERT INTO book_inventory (book_id, on_hand) VALUES ($1, $2)", bookID, onHand); err != nil {
+		t.Fatal(err)
+	}
+	return bookID
+}
+
+func stockOf(t *testing.T, tx *sql.Tx, bookID int) (onHand, reserved int) {
+	t.Helper()
+	if err := tx.QueryRow("SELECT on_hand, reserved FROM book_inventory WHERE book_id = $1", bookID).Scan(&onHand, &reserved); err != nil {
+		t.Fatal(err)
+	}
+	return onHand, reserved
+}
+
+// setReservationLimits ตั้งโควตาการจองชั่วคราวแล้วคืนค่าเดิมเมื่อจบ test
+func setReservationLimits(t *testing.T, perBook, perUser int) {
+	t.Helper()
+	oldBook, oldUser := reservationMaxPerBook, reservationMaxPerUser
+	reservationMaxPerBook, reservationMaxPerUser = perBook, perUser
+	t.Cleanup(func() { reservationMaxPerBook, reservationMaxPerUser = oldBook, oldUser })
+}
+
+func TestCheckReservationLimits(t *testing.T) {
+	setReservationLimits(t, 5, 20)
+
+	tests := []struct {
+		name                   string
+		heldForBook, heldTotal int
+		quantity               int
+		ok                     bool
+	}{
+		{"first reservation", 0, 0, 1, true},
+		{"up to the per-book cap", 3, 3, 2, true},
+		{"over the per-book cap", 4, 4, 2, false},
+		{"up to the per-user cap", 0, 15, 5, true},
+		{"over the per-user cap", 0, 18, 3, false},
+		{"single request above the per-book cap", 0, 0, 6, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkReservationLimits(tt.heldForBook, tt.heldTotal, tt.quantity)
+			if tt.ok && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.ok && err != errReservationLimit {
+				t.Fatalf("err = %v, want errReservationLimit", err)
+			}
+		})
+	}
+}
+
+func TestRateLimiterAllowsLimitPerWindow(t *testing.T) {
+	l := newRateLimiter(2, time.Minute)
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.allow("user:1"); !ok {
+			t.Fatalf("request %d rejected", i+1)
+		}
+	}
+	ok, retryAfter := l.allow("user:1")
+	if ok || retryAfter <= 0 {
+		t.Fatalf("third request: ok=%v retryAfter=%v, want rejected with a retry time", ok, retryAfter)
+	}
+	if ok, _ := l.allow("user:2"); !ok {
+		t.Fatal("another key shares the limit")
+	}
+}
+
+func TestReserveStockLimits(t *testing.T) {
+	conn := openTestDB(t)
+	setReservationLimits(t, 3, 4)
+
+	withTestTx(t, conn, func(tx *sql.Tx) {
+		userID := insertTestUser(t, tx)
+		first := insertStockedBook(t, tx, 10)
+		second := insertStockedBook(t, tx, 10)
+
+		if _, err := reserveStock(tx, first, userID, 3); err != nil {
+			t.Fatalf("reserve up to the per-book cap: %v", err)
+		}
+		if _, err := reserveStock(tx, first, userID, 1); err != errReservationLimit {
+			t.Fatalf("over the per-book cap: err = %v, want errReservationLimit", err)
+		}
+		if _, err := reserveStock(tx, second, userID, 2); err != errReservationLimit {
+			t.Fatalf("over the per-user cap: err = %v, want errReservationLimit", err)
+		}
+		if _, err := reserveStock(tx, second, userID, 1); err != nil {
+			t.Fatalf("reserve up to the per-user cap: %v", err)
+		}
+		if _, reserved := stockOf(t, tx, first); reserved != 3 {
+			t.Fatalf("reserved = %d, want 3", reserved)
+		}
+	})
+}
+
+func TestReserveStockInsufficient(t *testing.T) {
+	conn := openTestDB(t)
+	setReservationLimits(t, 5, 20)
+
+	withTestTx(t, conn, func(tx *sql.Tx) {
+		userID := insertTestUser(t, tx)
+		bookID := insertStockedBook(t, tx, 2)
+
+		if _, err := reserveStock(tx, bookID, userID, 3); err != errInsufficientStock {
+			t.Fatalf("err = %v, want errInsufficientStock", err)
+		}
+		if _, reserved := stockOf(t, tx, bookID); reserved != 0 {
+			t.Fatalf("reserved = %d after a rejected reservation", reserved)
+		}
+	})
+}
+
+func TestCommitStockReleasesUnpurchasedReservation(t *testing.T) {
+	conn := openTestDB(t)
+	setReservationLimits(t, 5, 20)
+
+	withTestTx(t, conn, func(tx *sql.Tx) {
+		userID := insertTestUser(t, tx)
+		bookID := insertStockedBook(t, tx, 10)
+		if _, err := reserveStock(tx, bookID, userID, 2); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := reserveStock(tx, bookID, userID, 3); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := lockInventory(tx, bookID); err != nil {
+			t.Fatal(err)
+		}
+		if err := commitStock(tx, bookID, userID, 3); err != nil {
+			t.Fatal(err)
+		}
+
+		onHand, reserved := stockOf(t, tx, bookID)
+		if onHand != 7 || reserved != 0 {
+			t.Fatalf("on_hand=%d reserved=%d, want 7 and 0", onHand, reserved)
+		}
+
+		var committed, released, active int
+		err := tx.QueryRow(`
+			SELECT COALESCE(SUM(quantity) FILTER (WHERE status = 'committed'), 0),
+			       COALESCE(SUM(quantity) FILTER (WHERE status = 'released'), 0),
+			       COALESCE(SUM(quantity) FILTER (WHERE status = 'active'), 0)
+			FROM stock_reservations WHERE book_id = $1`, bookID).Scan(&committed, &released, &active)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if committed != 3 || released != 2 || active != 0 {
+			t.Fatalf("committed=%d released=%d active=%d, want 3, 2 and 0", committed, released, active)
+		}
+	})
+}
@@ -39,12 +39,18 @@ const BookCard = ({ book }) => {
               ใหม่
             </span>
           )}
-          {book.discount && (
+          {book.discount > 0 && (
             <span className="absolute top-3 right-3 bg-red-500 text-white px-3 py-1 
               rounded-full text-xs font-semibold">
               -{book.discount}%
             </span>
           )}
+          {book.stock_available === 0 && (
+            <span className="absolute bottom-3 left-3 bg-gray-800 text-white px-3 py-1 
+              rounded-full text-xs font-semibold">
+              สินค้าหมด
+            </span>
+          )}
           
           {/* Quick Actions - Show on Hover */}
           <div className="absolute inset-0 bg-black bg-opacity-0 group-hover:bg-opacity-40 
@@ -108,13 +114,13 @@ const BookCard = ({ book }) => {
           {/* Price */}
           <div className="flex items-center justify-between">
             <div>
-              {book.originalPrice && book.originalPrice !== book.price && (
+              {book.sale_price < book.price && (
                 <span className="text-sm text-gray-400 line-through mr-2">
-                  ฿{book.originalPrice}
+                  ฿{book.price}
                 </span>
               )}
               <span className="text-2xl font-bold text-viridian-600">
-                ฿{book.price}
+                ฿{book.sale_price ?? book.price}
               </span>
             </div>
             
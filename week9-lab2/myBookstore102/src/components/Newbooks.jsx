@@ -53,10 +53,10 @@ function Newbooks({ books = [] }) {
             
             <div className="flex items-center justify-between">
               <div>
-                <span className="text-green-600 font-bold text-xl">฿{book.price}</span>
-                {book.original_price && book.discount > 0 && (
+                <span className="text-green-600 font-bold text-xl">฿{book.sale_price ?? book.price}</span>
+                {book.sale_price < book.price && (
                   <span className="text-gray-400 line-through text-sm ml-2">
-                    ฿{book.original_price}
+                    ฿{book.price}
                   </span>
                 )}
               </div>
@@ -90,12 +90,12 @@ function BookDetailPage() {
           <div className="mb-6">
             <div className="flex items-center gap-4">
               <span className="text-3xl font-bold text-green-600">
-                ฿{book.price}
+                ฿{book.sale_price ?? book.price}
               </span>
-              {book.discount > 0 && (
+              {book.sale_price < book.price && (
                 <>
                   <span className="text-xl text-gray-400 line-through">
-                    ฿{book.original_price}
+                    ฿{book.price}
                   </span>
                   <span className="bg-red-500 text-white px-2 py-1 rounded">
                     -{book.discount}% OFF
@@ -103,6 +103,11 @@ function BookDetailPage() {
                 </>
               )}
             </div>
+            {book.sale_price < book.price && book.lowest_price_30d != null && (
+              <p className="text-sm text-gray-500 mt-1">
+                ราคาต่ำสุดใน 30 วันก่อนลดราคา ฿{book.lowest_price_30d}
+              </p>
+            )}
           </div>
           
           {book.description && (
@@ -15,8 +15,6 @@ function EditBookPage() {
     year: new Date().getFullYear(),
     price: '',
     category: '',
-    original_price: '',
-    discount: 0,
     cover_image: '',
     rating: 0,
     reviews_count: 0,
@@ -62,8 +60,6 @@ function EditBookPage() {
         ...formData,
         year: parseInt(formData.year) || new Date().getFullYear(),
         price: parseFloat(formData.price) || 0,
-        original_price: parseFloat(formData.original_price) || parseFloat(formData.price) || 0,
-        discount: parseFloat(formData.discount) || 0,
         rating: parseFloat(formData.rating) || 0,
         reviews_count: parseInt(formData.reviews_count) || 0,
         pages: parseInt(formData.pages) || 0,
@@ -170,7 +166,7 @@ function EditBookPage() {
           <div className="grid md:grid-cols-3 gap-6">
             <div>
               <label className="block text-sm font-semibold text-gray-700 mb-2">
-                ราคาขาย <span className="text-red-500">*</span>
+                ราคาปกติ <span className="text-red-500">*</span>
               </label>
               <input
                 type="number"
@@ -183,33 +179,6 @@ function EditBookPage() {
                 className="w-full px-4 py-2 border border-gray-300 rounded-lg focus:ring-2 focus:ring-green-500 focus:border-transparent"
               />
             </div>
-
-            <div>
-              <label className="block text-sm font-semibold text-gray-700 mb-2">ราคาเต็ม</label>
-              <input
-                type="number"
-                name="original_price"
-                value={formData.original_price}
-                onChange={handleChange}
-                step="0.01"
-                min="0"
-                className="w-full px-4 py-2 border border-gray-300 rounded-lg focus:ring-2 focus:ring-green-500 focus:border-transparent"
-              />
-            </div>
-
-            <div>
-              <label className="block text-sm font-semibold text-gray-700 mb-2">ส่วนลด (%)</label>
-              <input
-                type="number"
-                name="discount"
-                value={formData.discount}
-                onChange={handleChange}
-                step="0.01"
-                min="0"
-                max="100"
-                className="w-full px-4 py-2 border border-gray-300 rounded-lg focus:ring-2 focus:ring-green-500 focus:border-transparent"
-              />
-            </div>
           </div>
 
           <div className="grid md:grid-cols-3 gap-6">
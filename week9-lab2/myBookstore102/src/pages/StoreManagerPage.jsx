@@ -11,6 +11,10 @@ function StoreManagerPage() {
   const [error, setError] = useState(null);
   const [selectedCategory, setSelectedCategory] = useState('');
   const [searchQuery, setSearchQuery] = useState('');
+  // รายการหนังสือแบ่งหน้า เก็บ cursor ของหน้าถัดไปและจำนวนทั้งหมดจาก response.meta
+  const [nextCursor, setNextCursor] = useState(null);
+  const [total, setTotal] = useState(0);
+  const [loadingMore, setLoadingMore] = useState(false);
   const navigate = useNavigate();
 
   useEffect(() => {
@@ -26,15 +30,27 @@ function StoreManagerPage() {
     fetchCategories();
   }, [selectedCategory, navigate]);
 
+  const setPage = (response, append) => {
+    const data = response.data || [];
+    setBooks((prev) => (append ? [...prev, ...data] : data));
+    setNextCursor(response.meta?.next_cursor || null);
+    setTotal(response.meta?.total ?? data.length);
+  };
+
+  const listParams = () => {
+    const params = {};
+    if (selectedCategory) {
+      params.category = selectedCategory;
+    }
+    return params;
+  };
+
   const fetchBooks = async () => {
     try {
       setLoading(true);
-      const params = {};
-      if (selectedCategory) {
-        params.category = selectedCategory;
-      }
-      const response = await booksAPI.getAll(params);
-      setBooks(response.data || []);
+      setSearchQuery('');
+      const response = await booksAPI.getAll(listParams());
+      setPage(response, false);
       setError(null);
     } catch (err) {
       setError('Failed to fetch books: ' + err.message);
@@ -63,7 +79,7 @@ function StoreManagerPage() {
       setLoading(true);
       setSearchQuery(query);
       const response = await booksAPI.search(query);
-      setBooks(response.data || []);
+      setPage(response, false);
       setError(null);
     } catch (err) {
       setError('Search failed: ' + err.message);
@@ -73,6 +89,24 @@ function StoreManagerPage() {
     }
   };
 
+  const loadMore = async () => {
+    if (!nextCursor) return;
+    try {
+      setLoadingMore(true);
+      const params = { cursor: nextCursor };
+      const response = searchQuery
+        ? await booksAPI.search(searchQuery, params)
+        : await booksAPI.getAll({ ...listParams(), ...params });
+      setPage(response, true);
+      setError(null);
+    } catch (err) {
+      setError('Failed to fetch books: ' + err.message);
+      console.error('Error fetching more books:', err);
+    } finally {
+      setLoadingMore(false);
+    }
+  };
+
   const handleCategoryChange = (category) => {
     setSelectedCategory(category);
     setSearchQuery('');
@@ -134,19 +168,19 @@ function StoreManagerPage() {
                   : 'bg-gray-200 text-gray-700 hover:bg-gray-300'
               }`}
             >
-              ทุกหมวดหมู่ ({books.length})
+              ทุกหมวดหมู่ ({total})
             </button>
             {categories.map((category) => (
               <button
-                key={category}
-                onClick={() => handleCategoryChange(category)}
+                key={category.id}
+                onClick={() => handleCategoryChange(category.name)}
                 className={`px-4 py-2 rounded-lg transition ${
-                  selectedCategory === category
+                  selectedCategory === category.name
                     ? 'bg-green-600 text-white'
                     : 'bg-gray-200 text-gray-700 hover:bg-gray-300'
                 }`}
               >
-                {category}
+                {category.name}
               </button>
             ))}
           </div>
@@ -229,11 +263,11 @@ function StoreManagerPage() {
                         
                         <div className="flex items-center gap-4 mt-3">
                           <div>
-                            <span className="text-green-600 font-bold text-2xl">฿{book.price}</span>
-                            {book.original_price && book.discount > 0 && (
+                            <span className="text-green-600 font-bold text-2xl">฿{book.sale_price ?? book.price}</span>
+                            {book.sale_price < book.price && (
                               <>
                                 <span className="text-gray-400 line-through text-lg ml-2">
-                                  ฿{book.original_price}
+                                  ฿{book.price}
                                 </span>
                                 <span className="ml-2 px-2 py-1 bg-red-100 text-red-600 text-sm font-bold rounded">
                                   ลด {book.discount}%
@@ -298,6 +332,19 @@ function StoreManagerPage() {
             ))}
           </div>
         )}
+
+        {nextCursor && (
+          <div className="mt-8 text-center">
+            <p className="text-gray-500 text-sm mb-3">แสดง {books.length} จาก {total} เล่ม</p>
+            <button
+              onClick={loadMore}
+              disabled={loadingMore}
+              className="px-6 py-2 bg-green-600 text-white rounded-lg hover:bg-green-700 transition disabled:opacity-50"
+            >
+              {loadingMore ? 'กำลังโหลด...' : 'โหลดเพิ่ม'}
+            </button>
+          </div>
+        )}
       </div>
     </div>
   );
@@ -57,6 +57,15 @@ api.interceptors.request.use(
 api.interceptors.response.use(
   (response) => {
     console.log('✅ API Response:', response.config.url, response.status);
+
+    // endpoint รายการหนังสือตอบเป็น { data, next_cursor, total, limit }
+    // แกะ data ออกมาให้หน้าเดิมที่ต้องการ array ใช้ได้ต่อ และเก็บ metadata ไว้ที่ response.meta
+    const body = response.data;
+    if (body && Array.isArray(body.data) && 'next_cursor' in body) {
+      response.meta = { next_cursor: body.next_cursor, total: body.total, limit: body.limit };
+      response.data = body.data;
+    }
+
     return response;
   },
   (error) => {
@@ -69,21 +78,22 @@ api.interceptors.response.use(
   }
 );
 
-// Helper: ดึงข้อมูลจาก /books แล้ว filter
+// Helper: ดึงข้อมูลจาก /books ทีละหน้าตาม next_cursor แล้ว filter จนได้ครบ limit หรือหมดรายการ
 const getBooksAndFilter = async (filterFn, limit = 8) => {
   try {
-    console.log('📚 Fetching all books from /books endpoint...');
-    const response = await api.get('/books');
-    
-    if (!response.data) {
-      console.warn('⚠️ No data in response');
-      return { ...response, data: [] };
-    }
-    
-    const books = Array.isArray(response.data) ? response.data : [];
-    console.log(`📚 Got ${books.length} books, filtering...`);
-    
-    const filtered = filterFn ? books.filter(filterFn).slice(0, limit) : books.slice(0, limit);
+    console.log('📚 Fetching books from /books endpoint...');
+    let filtered = [];
+    let cursor = null;
+    let response;
+    do {
+      response = await api.get('/books', { params: cursor ? { cursor } : {} });
+      const books = Array.isArray(response.data) ? response.data : [];
+      console.log(`📚 Got ${books.length} books, filtering...`);
+      filtered = filtered.concat(filterFn ? books.filter(filterFn) : books);
+      cursor = response.meta?.next_cursor || null;
+    } while (cursor && filtered.length < limit);
+
+    filtered = filtered.slice(0, limit);
     console.log(`✅ Filtered to ${filtered.length} books`);
     
     return { ...response, data: filtered };
@@ -96,12 +106,13 @@ const getBooksAndFilter = async (filterFn, limit = 8) => {
 // Books API
 export const booksAPI = {
   // Basic CRUD - ใช้ตรงๆ
+  // รายการหนังสือแบ่งหน้า (default 20 เล่ม) ส่ง params.cursor = response.meta.next_cursor เพื่อดึงหน้าถัดไป
   getAll: (params) => api.get('/books', { params }),
   getById: (id) => api.get(`/books/${id}`),
   create: (book) => api.post('/books', book),
   update: (id, book) => api.put(`/books/${id}`, book),
   delete: (id) => api.delete(`/books/${id}`),
-  search: (query) => api.get('/books/search', { params: { q: query } }),
+  search: (query, params) => api.get('/books/search', { params: { q: query, ...params } }),
   
   // Custom endpoints - fallback ไปใช้ /books แทน
   getFeatured: async (limit = 8) => {